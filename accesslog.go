@@ -0,0 +1,133 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+
+	"connectrpc.com/connect"
+)
+
+// DefaultAccessLogLevels maps codes to the level their access logs are written at. Codes which
+// are not present are logged at slog.LevelError.
+var DefaultAccessLogLevels = map[string]slog.Level{
+	CodeOk:                                  slog.LevelInfo,
+	connect.CodeCanceled.String():           slog.LevelInfo,
+	connect.CodeInvalidArgument.String():    slog.LevelInfo,
+	connect.CodeNotFound.String():           slog.LevelInfo,
+	connect.CodeAlreadyExists.String():      slog.LevelInfo,
+	connect.CodeUnauthenticated.String():    slog.LevelInfo,
+	connect.CodeDeadlineExceeded.String():   slog.LevelWarn,
+	connect.CodePermissionDenied.String():   slog.LevelWarn,
+	connect.CodeResourceExhausted.String():  slog.LevelWarn,
+	connect.CodeFailedPrecondition.String(): slog.LevelWarn,
+	connect.CodeAborted.String():            slog.LevelWarn,
+	connect.CodeOutOfRange.String():         slog.LevelWarn,
+	connect.CodeUnavailable.String():        slog.LevelWarn,
+}
+
+// NewAccessLogger creates an access logger writing one record to logger for every completed RPC or
+// stream, with its code, latency, peer, and the messages and bytes sent and received. Use it with
+// WithAccessLogger.
+func NewAccessLogger(logger *slog.Logger, opts ...AccessLogOption) *AccessLogger {
+	options := evaluateAccessLogOptions(&accessLogOptions{
+		sampleRate: 1,
+		levels:     make(map[string]slog.Level),
+	}, opts...)
+
+	levels := make(map[string]slog.Level, len(DefaultAccessLogLevels)+len(options.levels))
+	for code, level := range DefaultAccessLogLevels {
+		levels[code] = level
+	}
+	for code, level := range options.levels {
+		levels[code] = level
+	}
+
+	return &AccessLogger{
+		logger:     logger,
+		sampleRate: options.sampleRate,
+		levels:     levels,
+	}
+}
+
+var _ callObserver = (*AccessLogger)(nil)
+
+type AccessLogger struct {
+	logger     *slog.Logger
+	sampleRate float64
+	levels     map[string]slog.Level
+}
+
+func (l *AccessLogger) callStarted(context.Context, *call) {}
+
+func (l *AccessLogger) callFinished(ctx context.Context, c *call) {
+	level, ok := l.levels[c.code]
+	if !ok {
+		level = slog.LevelError
+	}
+	// Only successful calls are sampled, failures are always logged.
+	if c.code == CodeOk && l.sampleRate < 1 && rand.Float64() >= l.sampleRate {
+		return
+	}
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+
+	msg := "finished server call"
+	if c.isClient {
+		msg = "finished client call"
+	}
+	l.logger.LogAttrs(ctx, level, msg, callAttrs(c)...)
+}
+
+// callAttrs returns the attributes describing a finished call.
+func callAttrs(c *call) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("type", c.callType),
+		slog.String("service", c.service),
+		slog.String("method", c.method),
+		slog.String("code", c.code),
+		slog.Duration("latency", c.duration),
+		slog.String("peer", c.peer),
+		slog.Int64("msg_sent", c.msgSent.Load()),
+		slog.Int64("msg_received", c.msgReceived.Load()),
+		slog.Int64("bytes_sent", c.bytesSent.Load()),
+		slog.Int64("bytes_received", c.bytesReceived.Load()),
+	}
+	if c.panicked {
+		attrs = append(attrs, slog.Bool("panic", true))
+	}
+	if c.err != nil {
+		attrs = append(attrs, slog.String("error", c.err.Error()))
+	}
+	return attrs
+}
+
+type accessLogOptions struct {
+	sampleRate float64
+	levels     map[string]slog.Level
+}
+
+type AccessLogOption func(*accessLogOptions)
+
+// WithAccessLogSampleRate logs only the given fraction of successful calls, between 0 and 1.
+// Failed calls are always logged. Defaults to logging every call.
+func WithAccessLogSampleRate(rate float64) AccessLogOption {
+	return func(opts *accessLogOptions) {
+		opts.sampleRate = rate
+	}
+}
+
+// WithAccessLogLevel overrides the level calls completing with code are logged at, see DefaultAccessLogLevels.
+func WithAccessLogLevel(code string, level slog.Level) AccessLogOption {
+	return func(opts *accessLogOptions) {
+		opts.levels[code] = level
+	}
+}
+
+func evaluateAccessLogOptions(defaults *accessLogOptions, opts ...AccessLogOption) *accessLogOptions {
+	for _, opt := range opts {
+		opt(defaults)
+	}
+	return defaults
+}
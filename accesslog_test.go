@@ -0,0 +1,70 @@
+package connect_go_prometheus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	accessLogger := NewAccessLogger(logger, WithAccessLogLevel(connect.CodeUnimplemented.String(), slog.LevelDebug))
+
+	interceptor := NewInterceptor(WithClientMetrics(nil), WithServerMetrics(nil), WithAccessLogger(accessLogger))
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetconnect.UnimplementedGreetServiceHandler{}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	createClientAndRequest(t, srv, interceptor)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2, "must log both the server-side and the client-side of the call")
+
+	records := make(map[string]map[string]any)
+	for _, line := range lines {
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records[record["msg"].(string)] = record
+	}
+
+	server := records["finished server call"]
+	require.NotNil(t, server)
+	require.Equal(t, "DEBUG", server["level"])
+	require.Equal(t, "unary", server["type"])
+	require.Equal(t, greetconnect.GreetServiceName, server["service"])
+	require.Equal(t, "Greet", server["method"])
+	require.Equal(t, connect.CodeUnimplemented.String(), server["code"])
+	require.EqualValues(t, 1, server["msg_received"])
+	require.EqualValues(t, 0, server["msg_sent"])
+	require.Positive(t, server["bytes_received"])
+	require.Contains(t, server, "latency")
+	require.Contains(t, server, "error")
+
+	client := records["finished client call"]
+	require.NotNil(t, client)
+	require.EqualValues(t, 1, client["msg_sent"])
+}
+
+func TestAccessLogger_Sampling(t *testing.T) {
+	var buf bytes.Buffer
+	accessLogger := NewAccessLogger(slog.New(slog.NewTextHandler(&buf, nil)), WithAccessLogSampleRate(0))
+
+	c := newCall(connect.Spec{Procedure: greetconnect.GreetServiceGreetProcedure}, connect.Peer{}, nil, nil, nil)
+	c.code = CodeOk
+	accessLogger.callFinished(context.Background(), c)
+	require.Empty(t, buf.String(), "successful calls are sampled")
+
+	c.code = connect.CodeInternal.String()
+	accessLogger.callFinished(context.Background(), c)
+	require.Contains(t, buf.String(), "level=ERROR")
+}
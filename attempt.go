@@ -0,0 +1,85 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// maxAttemptLabel bounds the values of the attempt label, attempts from maxAttemptLabel onwards
+// are reported together.
+const maxAttemptLabel = 5
+
+type attemptKey struct{}
+
+// WithAttempt returns a context marking a client-side RPC as the given attempt of a logical call,
+// starting at 1. Retrying interceptors and clients should set it before invoking each attempt.
+func WithAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+// AttemptFromContext returns the attempt set by WithAttempt. RPCs which are not marked are first attempts.
+func AttemptFromContext(ctx context.Context) int {
+	if attempt, ok := ctx.Value(attemptKey{}).(int); ok && attempt > 0 {
+		return attempt
+	}
+	return 1
+}
+
+// attemptLabel returns the value of the attempt label for the attempt in ctx.
+func attemptLabel(ctx context.Context) string {
+	return boundedAttempt(AttemptFromContext(ctx))
+}
+
+// boundedAttempt returns the value of the attempt label for attempt.
+func boundedAttempt(attempt int) string {
+	if attempt >= maxAttemptLabel {
+		return strconv.Itoa(maxAttemptLabel) + "+"
+	}
+	return strconv.Itoa(attempt)
+}
+
+type logicalCallKey struct{}
+
+// logicalCall tracks the attempts of a logical call, to report its final outcome once it completes.
+type logicalCall struct {
+	mu       sync.Mutex
+	reporter Recorder
+	attempts int
+	// callType, service, method and code are those of the last attempt to finish.
+	callType, service, method, code string
+	done                            bool
+}
+
+// WithLogicalCall returns a context for the attempts of a logical call, and a function to call once the
+// logical call completes, after its last attempt. The function reports the code of the last attempt, and
+// the number of attempts made, to the client-side counter of logical calls enabled by WithAttemptMetrics,
+// exposing the final outcome of calls which were retried. Calling it more than once has no effect.
+func WithLogicalCall(ctx context.Context) (context.Context, func()) {
+	lc := &logicalCall{}
+	return context.WithValue(ctx, logicalCallKey{}, lc), lc.finish
+}
+
+func logicalCallFromContext(ctx context.Context) *logicalCall {
+	lc, _ := ctx.Value(logicalCallKey{}).(*logicalCall)
+	return lc
+}
+
+// attemptFinished records c as the latest attempt of the logical call.
+func (lc *logicalCall) attemptFinished(r Recorder, c *call) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.reporter = r
+	lc.attempts++
+	lc.callType, lc.service, lc.method, lc.code = c.callType, c.service, c.method, c.code
+}
+
+func (lc *logicalCall) finish() {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if lc.done || lc.reporter == nil {
+		return
+	}
+	lc.done = true
+	reportLogicalCall(lc.reporter, lc.callType, lc.service, lc.method, boundedAttempt(lc.attempts), lc.code)
+}
@@ -0,0 +1,94 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttemptFromContext(t *testing.T) {
+	require.Equal(t, 1, AttemptFromContext(context.Background()))
+	require.Equal(t, 3, AttemptFromContext(WithAttempt(context.Background(), 3)))
+
+	require.Equal(t, "1", attemptLabel(context.Background()))
+	require.Equal(t, "4", attemptLabel(WithAttempt(context.Background(), 4)))
+	require.Equal(t, "5+", attemptLabel(WithAttempt(context.Background(), 5)))
+	require.Equal(t, "5+", attemptLabel(WithAttempt(context.Background(), 12)))
+}
+
+func TestInterceptor_WithAttemptMetrics(t *testing.T) {
+	clientMetrics := NewClientMetrics(WithAttemptMetrics(true))
+	interceptor := NewInterceptor(WithClientMetrics(clientMetrics), WithServerMetrics(nil))
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetconnect.UnimplementedGreetServiceHandler{})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, connect.WithInterceptors(interceptor))
+	for attempt := 1; attempt <= 3; attempt++ {
+		_, err := client.Greet(WithAttempt(context.Background(), attempt), connect.NewRequest(&greet.GreetRequest{Name: "eliza"}))
+		require.Equal(t, connect.CodeUnimplemented, connect.CodeOf(err))
+	}
+
+	code := connect.CodeUnimplemented.String()
+	require.EqualValues(t, 1, testutil.ToFloat64(clientMetrics.attempts.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet", "1", code)))
+	require.EqualValues(t, 1, testutil.ToFloat64(clientMetrics.attempts.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet", "2", code)))
+	require.EqualValues(t, 1, testutil.ToFloat64(clientMetrics.attempts.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet", "3", code)))
+	require.EqualValues(t, 3, testutil.ToFloat64(clientMetrics.requestStarted.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet")))
+}
+
+// flakyGreetServiceHandler fails every other call as unavailable, starting with the first.
+type flakyGreetServiceHandler struct {
+	greetconnect.UnimplementedGreetServiceHandler
+	calls atomic.Int64
+}
+
+func (h *flakyGreetServiceHandler) Greet(context.Context, *connect.Request[greet.GreetRequest]) (*connect.Response[greet.GreetResponse], error) {
+	if h.calls.Add(1)%2 == 1 {
+		return nil, connect.NewError(connect.CodeUnavailable, errors.New("overloaded"))
+	}
+	return connect.NewResponse(&greet.GreetResponse{}), nil
+}
+
+func TestInterceptor_WithLogicalCall(t *testing.T) {
+	clientMetrics := NewClientMetrics(WithAttemptMetrics(true))
+	interceptor := NewInterceptor(WithClientMetrics(clientMetrics), WithServerMetrics(nil))
+
+	_, handler := greetconnect.NewGreetServiceHandler(&flakyGreetServiceHandler{})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, connect.WithInterceptors(interceptor))
+	greetWithRetries := func(maxAttempts int) {
+		ctx, done := WithLogicalCall(context.Background())
+		defer done()
+		for attempt := 1; attempt <= maxAttempts; attempt++ {
+			_, err := client.Greet(WithAttempt(ctx, attempt), connect.NewRequest(&greet.GreetRequest{Name: "eliza"}))
+			if connect.CodeOf(err) != connect.CodeUnavailable {
+				return
+			}
+		}
+	}
+	greetWithRetries(3)
+	greetWithRetries(1)
+
+	unavailable := connect.CodeUnavailable.String()
+	require.EqualValues(t, 1, testutil.ToFloat64(clientMetrics.logicalCalls.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet", "2", CodeOk)), "retried calls report the code of their last attempt")
+	require.EqualValues(t, 1, testutil.ToFloat64(clientMetrics.logicalCalls.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet", "1", unavailable)))
+	require.EqualValues(t, 2, testutil.ToFloat64(clientMetrics.attempts.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet", "1", unavailable)))
+	require.Equal(t, 2, testutil.CollectAndCount(clientMetrics.logicalCalls))
+
+	_, done := WithLogicalCall(context.Background())
+	done()
+	done()
+	require.Equal(t, 2, testutil.CollectAndCount(clientMetrics.logicalCalls), "logical calls without attempts are not reported")
+}
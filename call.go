@@ -0,0 +1,151 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// callObserver is notified of RPCs observed by the Interceptor, in addition to its Recorder.
+type callObserver interface {
+	callStarted(ctx context.Context, c *call)
+	callFinished(ctx context.Context, c *call)
+}
+
+// call is the state of a single RPC observed by the Interceptor, from the moment it starts
+// until its completion is reported.
+type call struct {
+	reporter  Recorder
+	observers []callObserver
+	needsSize bool
+
+	isClient                  bool
+	procedure                 string
+	callType, service, method string
+	peer                      string
+	header                    http.Header
+	start                     time.Time
+	timeout                   time.Duration
+	hasDeadline               bool
+
+	msgSent, msgReceived     atomic.Int64
+	bytesSent, bytesReceived atomic.Int64
+
+	// Set once the call has finished.
+	code     string
+	err      error
+	duration time.Duration
+	panicked bool
+}
+
+func newCall(spec connect.Spec, peer connect.Peer, header http.Header, reporter Recorder, observers []callObserver) *call {
+	service, method := procedureToPackageAndMethod(spec.Procedure)
+	return &call{
+		reporter:  reporter,
+		observers: observers,
+		needsSize: len(observers) > 0 || (reporter != nil && reportsBytes(reporter)),
+		isClient:  spec.IsClient,
+		procedure: spec.Procedure,
+		callType:  streamTypeString(spec.StreamType),
+		service:   service,
+		method:    method,
+		peer:      peer.Addr,
+		header:    header,
+		start:     time.Now(),
+		code:      connect.CodeUnknown.String(),
+	}
+}
+
+func (c *call) begin(ctx context.Context) {
+	c.timeout, c.hasDeadline = timeoutOf(ctx, c.header, c.start)
+	if c.reporter != nil {
+		c.reporter.ReportStarted(c.callType, c.service, c.method)
+		if !c.isClient && !c.hasDeadline {
+			reportNoDeadline(c.reporter, c.callType, c.service, c.method)
+		}
+	}
+	for _, o := range c.observers {
+		o.callStarted(ctx, c)
+	}
+}
+
+// sent records a message sent. Only stream messages are reported to the message metrics, unless the
+// recorder also counts unary messages.
+func (c *call) sent(message any, stream bool) {
+	c.msgSent.Add(1)
+	var size int
+	if c.needsSize {
+		size = sizeOf(message)
+		c.bytesSent.Add(int64(size))
+	}
+	if c.reporter != nil {
+		if stream {
+			c.reporter.ReportMsgSent(c.callType, c.service, c.method)
+		} else {
+			reportUnaryMsgSent(c.reporter, c.callType, c.service, c.method)
+		}
+		if reportsBytes(c.reporter) {
+			c.reporter.ReportBytesSent(c.callType, c.service, c.method, size)
+		}
+	}
+}
+
+// received records a message received. Only stream messages are reported to the message metrics, unless
+// the recorder also counts unary messages.
+func (c *call) received(message any, stream bool) {
+	c.msgReceived.Add(1)
+	var size int
+	if c.needsSize {
+		size = sizeOf(message)
+		c.bytesReceived.Add(int64(size))
+	}
+	if c.reporter != nil {
+		if stream {
+			c.reporter.ReportMsgReceived(c.callType, c.service, c.method)
+		} else {
+			reportUnaryMsgReceived(c.reporter, c.callType, c.service, c.method)
+		}
+		if reportsBytes(c.reporter) {
+			c.reporter.ReportBytesReceived(c.callType, c.service, c.method, size)
+		}
+	}
+}
+
+// finish reports the completion of the call. Panics are reported with an internal code.
+func (c *call) finish(ctx context.Context, err error, panicked bool) {
+	c.duration = time.Since(c.start)
+	c.err = err
+	c.panicked = panicked
+	if panicked {
+		c.code = connect.CodeInternal.String()
+	} else {
+		c.code = codeOf(err)
+	}
+
+	if r := c.reporter; r != nil {
+		if panicked {
+			reportPanicked(r, c.callType, c.service, c.method)
+		}
+		r.ReportHandled(c.callType, c.service, c.method, c.code)
+		r.ReportHandledSeconds(c.callType, c.service, c.method, c.code, c.duration.Seconds())
+		if c.isClient {
+			reportAttempt(r, c.callType, c.service, c.method, attemptLabel(ctx), c.code)
+			if lc := logicalCallFromContext(ctx); lc != nil {
+				lc.attemptFinished(r, c)
+			}
+		} else {
+			if source, ok := cancelSourceOf(ctx, c.code); ok {
+				reportCanceled(r, c.callType, c.service, c.method, c.code, source)
+			}
+			if c.hasDeadline {
+				reportDeadlineBudget(r, c.callType, c.service, c.method, deadlineRatio(c.duration, c.timeout))
+			}
+		}
+	}
+	for _, o := range c.observers {
+		o.callFinished(ctx, c)
+	}
+}
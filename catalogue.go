@@ -0,0 +1,85 @@
+package connect_go_prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricFamily describes a metric family produced by Metrics.
+type MetricFamily struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Help        string   `json:"help"`
+	Labels      []string `json:"labels"`
+	ConstLabels []string `json:"const_labels,omitempty"`
+}
+
+// Catalogue returns the metric families described by m, in the order they are described.
+func Catalogue(m *Metrics) []MetricFamily {
+	var families []MetricFamily
+	for _, desc := range describe(m) {
+		family, ok := m.families[desc]
+		if !ok {
+			continue
+		}
+		family.Labels = append([]string{}, family.Labels...)
+		family.ConstLabels = append([]string(nil), family.ConstLabels...)
+		families = append(families, family)
+	}
+	return families
+}
+
+// CatalogueMarkdown renders families as a Markdown table.
+func CatalogueMarkdown(families []MetricFamily) string {
+	var b strings.Builder
+	b.WriteString("| Name | Type | Labels | Help |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	for _, family := range families {
+		labels := make([]string, 0, len(family.Labels)+len(family.ConstLabels))
+		for _, label := range family.Labels {
+			labels = append(labels, "`"+label+"`")
+		}
+		for _, label := range family.ConstLabels {
+			labels = append(labels, "`"+label+"` (const)")
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s |\n", family.Name, family.Type, strings.Join(labels, ", "), family.Help)
+	}
+	return b.String()
+}
+
+// CatalogueJSON renders families as JSON.
+func CatalogueJSON(families []MetricFamily) ([]byte, error) {
+	out, err := json.MarshalIndent(families, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal catalogue")
+	}
+	return out, nil
+}
+
+// describe returns the descriptors of c.
+func describe(c prom.Collector) []*prom.Desc {
+	ch := make(chan *prom.Desc)
+	go func() {
+		c.Describe(ch)
+		close(ch)
+	}()
+
+	var descs []*prom.Desc
+	for desc := range ch {
+		descs = append(descs, desc)
+	}
+	return descs
+}
+
+// describedNames returns the fully-qualified names of the metrics described by m.
+func describedNames(m *Metrics) map[string]bool {
+	names := make(map[string]bool)
+	for _, family := range Catalogue(m) {
+		names[family.Name] = true
+	}
+	return names
+}
@@ -0,0 +1,95 @@
+package connect_go_prometheus
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"regexp"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+var updateREADME = flag.Bool("update-readme", false, "Update the metric catalogue in README.md")
+
+// readmeServerMetrics and readmeClientMetrics are the metrics listed in README.md, with every option enabled.
+func readmeServerMetrics() *Metrics {
+	return NewServerMetrics(readmeServerMetricsOptions()...)
+}
+
+func readmeServerMetricsOptions() []MetricsOption {
+	return []MetricsOption{
+		WithHistogram(true),
+		WithByteMetrics(true),
+		WithInflightMetrics(true),
+		WithCancelSourceMetrics(true),
+		WithDeadlineMetrics(true),
+		WithSLOs(SLO{Procedure: "/greet.v1.GreetService/Greet", Availability: 0.999}),
+	}
+}
+
+func readmeClientMetrics() *Metrics {
+	return NewClientMetrics(readmeClientMetricsOptions()...)
+}
+
+func readmeClientMetricsOptions() []MetricsOption {
+	return []MetricsOption{
+		WithHistogram(true),
+		WithByteMetrics(true),
+		WithInflightMetrics(true),
+		WithTransportMetrics(true),
+		WithAttemptMetrics(true),
+	}
+}
+
+func TestCatalogue(t *testing.T) {
+	families := Catalogue(NewServerMetrics(WithConstLabels(prom.Labels{"component": "greeter"})))
+	require.Equal(t, MetricFamily{
+		Name:        "connect_server_started_total",
+		Type:        "counter",
+		Help:        "Total number of RPCs started handling server-side",
+		Labels:      []string{"type", "service", "method"},
+		ConstLabels: []string{"component"},
+	}, families[0])
+
+	for _, m := range []*Metrics{readmeServerMetrics(), readmeClientMetrics()} {
+		require.Len(t, Catalogue(m), len(describe(m)), "every metric family is catalogued")
+	}
+
+	out, err := CatalogueJSON(families[:1])
+	require.NoError(t, err)
+	var decoded []MetricFamily
+	require.NoError(t, json.Unmarshal(out, &decoded))
+	require.Equal(t, families[:1], decoded)
+
+	require.Equal(t, "| Name | Type | Labels | Help |\n"+
+		"| --- | --- | --- | --- |\n"+
+		"| `connect_server_started_total` | counter | `type`, `service`, `method`, `component` (const) | Total number of RPCs started handling server-side |\n",
+		CatalogueMarkdown(families[:1]))
+}
+
+var readmeCatalogue = regexp.MustCompile(`(?s)(<!-- catalogue:(server|client):begin -->\n).*?(<!-- catalogue:(?:server|client):end -->)`)
+
+// TestREADMECatalogue fails when the metrics listed in README.md drift from those produced.
+// Run it with -update-readme to regenerate them.
+func TestREADMECatalogue(t *testing.T) {
+	readme, err := os.ReadFile("README.md")
+	require.NoError(t, err)
+
+	generated := readmeCatalogue.ReplaceAllStringFunc(string(readme), func(section string) string {
+		match := readmeCatalogue.FindStringSubmatch(section)
+		m := readmeServerMetrics()
+		if match[2] == "client" {
+			m = readmeClientMetrics()
+		}
+		return match[1] + CatalogueMarkdown(Catalogue(m)) + match[3]
+	})
+	require.Len(t, readmeCatalogue.FindAllString(string(readme), -1), 2, "README.md must have a server and client catalogue")
+
+	if *updateREADME {
+		require.NoError(t, os.WriteFile("README.md", []byte(generated), 0o644))
+		return
+	}
+	require.Equal(t, generated, string(readme), "the metric catalogue in README.md is out of date, run go test -run TestREADMECatalogue -update-readme")
+}
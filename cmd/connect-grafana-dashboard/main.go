@@ -0,0 +1,67 @@
+// Command connect-grafana-dashboard generates a Grafana dashboard for the metrics of connect-go-prometheus,
+// using the exact metric names produced for the given options.
+//
+//	connect-grafana-dashboard -side server -namespace acme -histogram -bytes > connect-server.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	connect_go_prometheus "github.com/easyCZ/connect-go-prometheus"
+)
+
+func main() {
+	var (
+		side      = flag.String("side", "server", "Generate a dashboard for the server or client metrics")
+		namespace = flag.String("namespace", "", "Namespace of the metrics, as set WithNamespace")
+		subsystem = flag.String("subsystem", "", "Subsystem of the metrics, as set WithSubsystem")
+		histogram = flag.Bool("histogram", false, "Include duration panels, for metrics with WithHistogram(true)")
+		bytes     = flag.Bool("bytes", false, "Include byte panels, for metrics with WithByteMetrics(true)")
+		inflight  = flag.Bool("inflight", false, "Include an inflight panel, for metrics with WithInflightMetrics(true)")
+		title     = flag.String("title", "", "Title of the dashboard")
+		uid       = flag.String("uid", "", "Unique identifier of the dashboard")
+		grpcNames = flag.Bool("grpc-prometheus-names", false, "Use the go-grpc-prometheus names, as set WithGRPCPrometheusNames")
+	)
+	flag.Parse()
+
+	metricsOpts := []connect_go_prometheus.MetricsOption{
+		connect_go_prometheus.WithNamespace(*namespace),
+		connect_go_prometheus.WithSubsystem(*subsystem),
+		connect_go_prometheus.WithHistogram(*histogram),
+		connect_go_prometheus.WithByteMetrics(*bytes),
+		connect_go_prometheus.WithInflightMetrics(*inflight),
+	}
+	if *grpcNames {
+		metricsOpts = append(metricsOpts, connect_go_prometheus.WithGRPCPrometheusNames())
+	}
+
+	opts := []connect_go_prometheus.DashboardOption{
+		connect_go_prometheus.WithDashboardMetricsOptions(metricsOpts...),
+	}
+	if *title != "" {
+		opts = append(opts, connect_go_prometheus.WithDashboardTitle(*title))
+	}
+	if *uid != "" {
+		opts = append(opts, connect_go_prometheus.WithDashboardUID(*uid))
+	}
+
+	var (
+		dashboard []byte
+		err       error
+	)
+	switch *side {
+	case "server":
+		dashboard, err = connect_go_prometheus.ServerDashboard(opts...)
+	case "client":
+		dashboard, err = connect_go_prometheus.ClientDashboard(opts...)
+	default:
+		err = fmt.Errorf("unknown side %q, must be server or client", *side)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(dashboard)
+}
@@ -0,0 +1,63 @@
+// Command connect-prometheus-rules generates Prometheus recording and alerting rules for the metrics of
+// connect-go-prometheus, using the exact metric names produced for the given options.
+//
+//	connect-prometheus-rules -side server -namespace acme -histogram > connect.rules.yaml
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	connect_go_prometheus "github.com/easyCZ/connect-go-prometheus"
+)
+
+func main() {
+	var (
+		side       = flag.String("side", "server", "Generate rules for the server or client metrics")
+		namespace  = flag.String("namespace", "", "Namespace of the metrics, as set WithNamespace")
+		subsystem  = flag.String("subsystem", "", "Subsystem of the metrics, as set WithSubsystem")
+		histogram  = flag.Bool("histogram", false, "Generate latency rules, for metrics with WithHistogram(true)")
+		window     = flag.String("window", connect_go_prometheus.DefaultRulesWindow, "Range of the rate() in the rules")
+		forDur     = flag.String("for", connect_go_prometheus.DefaultRulesFor, "How long an alert condition must hold before firing")
+		errorRatio = flag.Float64("error-ratio", connect_go_prometheus.DefaultRulesErrorRatioThreshold, "Error ratio alerted on")
+		latency    = flag.Duration("latency", connect_go_prometheus.DefaultRulesLatencyThreshold, "p99 latency alerted on")
+		grpcNames  = flag.Bool("grpc-prometheus-names", false, "Use the go-grpc-prometheus names, as set WithGRPCPrometheusNames")
+	)
+	flag.Parse()
+
+	metricsOpts := []connect_go_prometheus.MetricsOption{
+		connect_go_prometheus.WithNamespace(*namespace),
+		connect_go_prometheus.WithSubsystem(*subsystem),
+		connect_go_prometheus.WithHistogram(*histogram),
+	}
+	if *grpcNames {
+		metricsOpts = append(metricsOpts, connect_go_prometheus.WithGRPCPrometheusNames())
+	}
+
+	opts := []connect_go_prometheus.RulesOption{
+		connect_go_prometheus.WithRulesMetricsOptions(metricsOpts...),
+		connect_go_prometheus.WithRulesWindow(*window),
+		connect_go_prometheus.WithRulesFor(*forDur),
+		connect_go_prometheus.WithRulesErrorRatioThreshold(*errorRatio),
+		connect_go_prometheus.WithRulesLatencyThreshold(*latency),
+	}
+
+	var (
+		rules []byte
+		err   error
+	)
+	switch *side {
+	case "server":
+		rules, err = connect_go_prometheus.ServerRules(opts...)
+	case "client":
+		rules, err = connect_go_prometheus.ClientRules(opts...)
+	default:
+		err = fmt.Errorf("unknown side %q, must be server or client", *side)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(rules)
+}
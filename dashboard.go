@@ -0,0 +1,330 @@
+package connect_go_prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// ServerDashboard returns a Grafana dashboard, as JSON, for the server-side metrics. Configure it
+// WithDashboardMetricsOptions set to the options of NewServerMetrics, so that the panels query the exact
+// metric names produced. It has rate, error and duration (RED) panels, and panels for the stream messages,
+// bytes and inflight RPCs when those metrics are enabled, filtered by service and method template variables.
+func ServerDashboard(opts ...DashboardOption) ([]byte, error) {
+	options := evaluateDashboardOptions(&dashboardOptions{
+		title: "Connect server",
+		uid:   "connect-server",
+	}, opts...)
+	return generateDashboard(serverMetricsOptions(), newServerMetrics, "server", options)
+}
+
+// ClientDashboard returns a Grafana dashboard, as JSON, for the client-side metrics, see ServerDashboard.
+// Configure it WithDashboardMetricsOptions set to the options of NewClientMetrics.
+func ClientDashboard(opts ...DashboardOption) ([]byte, error) {
+	options := evaluateDashboardOptions(&dashboardOptions{
+		title: "Connect client",
+		uid:   "connect-client",
+	}, opts...)
+	return generateDashboard(clientMetricsOptions(), newClientMetrics, "client", options)
+}
+
+// dashboardPanel describes a panel, included when the metric it queries is produced.
+type dashboardPanel struct {
+	title  string
+	unit   string
+	metric func(config *metricsOptions) string
+	// targets returns the queries of the panel, given the fully-qualified metric name and the label selector.
+	targets func(name, selector string) []dashboardTarget
+}
+
+func generateDashboard(defaults *metricsOptions, build func(*metricsOptions) *Metrics, side string, options *dashboardOptions) ([]byte, error) {
+	config := evaluateMetricsOptions(defaults, options.metricsOptions...)
+	if invalid, _ := validateMetricsOptions(config, build); len(invalid) > 0 {
+		return nil, metricsOptionsError(invalid)
+	}
+	fqName := func(name string) string {
+		return prom.BuildFQName(config.namespace, config.subsystem, name)
+	}
+	produced := describedNames(build(config))
+
+	var errorCodes []string
+	for _, code := range serverErrorCodes() {
+		errorCodes = append(errorCodes, labelValue(config.codeValues, code.String()))
+	}
+	errorSelector := fmt.Sprintf(`%s=~"%s", `, config.codeLabel, strings.Join(errorCodes, "|"))
+	by := config.serviceLabel + ", " + config.methodLabel
+	legend := fmt.Sprintf("{{%s}}/{{%s}}", config.serviceLabel, config.methodLabel)
+
+	sumRate := func(name, selector string) []dashboardTarget {
+		return []dashboardTarget{{
+			Expr:         fmt.Sprintf("sum by (%s) (rate(%s{%s}[$__rate_interval]))", by, name, selector),
+			LegendFormat: legend,
+		}}
+	}
+	sumGauge := func(name, selector string) []dashboardTarget {
+		return []dashboardTarget{{
+			Expr:         fmt.Sprintf("sum by (%s) (%s{%s})", by, name, selector),
+			LegendFormat: legend,
+		}}
+	}
+
+	panels := []dashboardPanel{
+		{
+			title:   "Requests",
+			unit:    "reqps",
+			metric:  func(c *metricsOptions) string { return c.requestHandledName },
+			targets: sumRate,
+		},
+		{
+			title:  "Error ratio",
+			unit:   "percentunit",
+			metric: func(c *metricsOptions) string { return c.requestHandledName },
+			targets: func(name, selector string) []dashboardTarget {
+				return []dashboardTarget{{
+					Expr: fmt.Sprintf("sum by (%s) (rate(%s{%s%s}[$__rate_interval])) / sum by (%s) (rate(%s{%s}[$__rate_interval]))",
+						by, name, errorSelector, selector, by, name, selector),
+					LegendFormat: legend,
+				}}
+			},
+		},
+		{
+			title:  "Responses by code",
+			unit:   "reqps",
+			metric: func(c *metricsOptions) string { return c.requestHandledName },
+			targets: func(name, selector string) []dashboardTarget {
+				return []dashboardTarget{{
+					Expr:         fmt.Sprintf("sum by (%s) (rate(%s{%s}[$__rate_interval]))", config.codeLabel, name, selector),
+					LegendFormat: "{{" + config.codeLabel + "}}",
+				}}
+			},
+		},
+		{
+			title:  "Duration",
+			unit:   "s",
+			metric: func(c *metricsOptions) string { return c.requestHandledSecondsName },
+			targets: func(name, selector string) []dashboardTarget {
+				var targets []dashboardTarget
+				for _, quantile := range rulesQuantiles {
+					targets = append(targets, dashboardTarget{
+						Expr:         fmt.Sprintf("histogram_quantile(%g, sum by (%s, le) (rate(%s_bucket{%s}[$__rate_interval])))", quantile.q, by, name, selector),
+						LegendFormat: quantile.name + " " + legend,
+					})
+				}
+				return targets
+			},
+		},
+		{
+			title:   "Inflight requests",
+			unit:    "short",
+			metric:  func(c *metricsOptions) string { return c.inflightRequestsName },
+			targets: sumGauge,
+		},
+		{
+			title:   "Stream messages sent",
+			unit:    "short",
+			metric:  func(c *metricsOptions) string { return c.streamMsgSentName },
+			targets: sumRate,
+		},
+		{
+			title:   "Stream messages received",
+			unit:    "short",
+			metric:  func(c *metricsOptions) string { return c.streamMsgReceivedName },
+			targets: sumRate,
+		},
+		{
+			title:   "Bytes sent",
+			unit:    "Bps",
+			metric:  func(c *metricsOptions) string { return c.bytesSentName },
+			targets: sumRate,
+		},
+		{
+			title:   "Bytes received",
+			unit:    "Bps",
+			metric:  func(c *metricsOptions) string { return c.bytesReceivedName },
+			targets: sumRate,
+		},
+		{
+			title:   "Panics",
+			unit:    "short",
+			metric:  func(c *metricsOptions) string { return c.panicsName },
+			targets: sumRate,
+		},
+	}
+
+	dashboard := grafanaDashboard{
+		Title:         options.title,
+		UID:           options.uid,
+		Tags:          []string{"connect", side},
+		Timezone:      "browser",
+		SchemaVersion: 39,
+		Refresh:       "30s",
+		Time:          grafanaTimeRange{From: "now-1h", To: "now"},
+	}
+
+	handled := fqName(config.requestHandledName)
+	datasource := grafanaDatasource{Type: "prometheus", UID: "${datasource}"}
+	dashboard.Templating.List = []grafanaVariable{
+		{
+			Name:  "datasource",
+			Label: "Data source",
+			Type:  "datasource",
+			Query: "prometheus",
+		},
+		{
+			Name:       "service",
+			Label:      "Service",
+			Type:       "query",
+			Datasource: &datasource,
+			Query:      fmt.Sprintf("label_values(%s, %s)", handled, config.serviceLabel),
+			Refresh:    2,
+			Multi:      true,
+			IncludeAll: true,
+		},
+		{
+			Name:       "method",
+			Label:      "Method",
+			Type:       "query",
+			Datasource: &datasource,
+			Query:      fmt.Sprintf(`label_values(%s{%s=~"$service"}, %s)`, handled, config.serviceLabel, config.methodLabel),
+			Refresh:    2,
+			Multi:      true,
+			IncludeAll: true,
+		},
+	}
+
+	selector := fmt.Sprintf(`%s=~"$service", %s=~"$method"`, config.serviceLabel, config.methodLabel)
+	for _, panel := range panels {
+		name := panel.metric(config)
+		if name == "" || !produced[fqName(name)] {
+			continue
+		}
+		targets := panel.targets(fqName(name), selector)
+		for i := range targets {
+			targets[i].RefID = string(rune('A' + i))
+		}
+		i := len(dashboard.Panels)
+		dashboard.Panels = append(dashboard.Panels, grafanaPanel{
+			ID:         i + 1,
+			Type:       "timeseries",
+			Title:      panel.title,
+			Datasource: datasource,
+			GridPos:    grafanaGridPos{H: 8, W: 12, X: (i % 2) * 12, Y: (i / 2) * 8},
+			Targets:    targets,
+			FieldConfig: grafanaFieldConfig{
+				Defaults: grafanaFieldDefaults{Unit: panel.unit},
+			},
+		})
+	}
+
+	out, err := json.MarshalIndent(dashboard, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal dashboard")
+	}
+	return out, nil
+}
+
+type grafanaDashboard struct {
+	Title         string           `json:"title"`
+	UID           string           `json:"uid"`
+	Tags          []string         `json:"tags"`
+	Timezone      string           `json:"timezone"`
+	SchemaVersion int              `json:"schemaVersion"`
+	Refresh       string           `json:"refresh"`
+	Time          grafanaTimeRange `json:"time"`
+	Templating    struct {
+		List []grafanaVariable `json:"list"`
+	} `json:"templating"`
+	Panels []grafanaPanel `json:"panels"`
+}
+
+type grafanaTimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type grafanaDatasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type grafanaVariable struct {
+	Name       string             `json:"name"`
+	Label      string             `json:"label"`
+	Type       string             `json:"type"`
+	Datasource *grafanaDatasource `json:"datasource,omitempty"`
+	Query      string             `json:"query"`
+	Refresh    int                `json:"refresh,omitempty"`
+	Multi      bool               `json:"multi,omitempty"`
+	IncludeAll bool               `json:"includeAll,omitempty"`
+}
+
+type grafanaPanel struct {
+	ID          int                `json:"id"`
+	Type        string             `json:"type"`
+	Title       string             `json:"title"`
+	Datasource  grafanaDatasource  `json:"datasource"`
+	GridPos     grafanaGridPos     `json:"gridPos"`
+	Targets     []dashboardTarget  `json:"targets"`
+	FieldConfig grafanaFieldConfig `json:"fieldConfig"`
+}
+
+type grafanaGridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type dashboardTarget struct {
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+	RefID        string `json:"refId"`
+}
+
+type grafanaFieldConfig struct {
+	Defaults grafanaFieldDefaults `json:"defaults"`
+}
+
+type grafanaFieldDefaults struct {
+	Unit string `json:"unit"`
+}
+
+type dashboardOptions struct {
+	metricsOptions []MetricsOption
+	title          string
+	uid            string
+}
+
+type DashboardOption func(*dashboardOptions)
+
+// WithDashboardMetricsOptions configures the metrics the dashboard is generated for, with the same options
+// as NewServerMetrics and NewClientMetrics.
+func WithDashboardMetricsOptions(opts ...MetricsOption) DashboardOption {
+	return func(o *dashboardOptions) {
+		o.metricsOptions = append(o.metricsOptions, opts...)
+	}
+}
+
+// WithDashboardTitle sets the title of the dashboard.
+func WithDashboardTitle(title string) DashboardOption {
+	return func(o *dashboardOptions) {
+		o.title = title
+	}
+}
+
+// WithDashboardUID sets the uid of the dashboard, which must be unique within a Grafana instance.
+func WithDashboardUID(uid string) DashboardOption {
+	return func(o *dashboardOptions) {
+		o.uid = uid
+	}
+}
+
+func evaluateDashboardOptions(defaults *dashboardOptions, opts ...DashboardOption) *dashboardOptions {
+	for _, opt := range opts {
+		opt(defaults)
+	}
+	return defaults
+}
@@ -0,0 +1,78 @@
+package connect_go_prometheus
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerDashboard(t *testing.T) {
+	out, err := ServerDashboard(WithDashboardMetricsOptions(WithNamespace("acme"), WithHistogram(true), WithByteMetrics(true)))
+	require.NoError(t, err)
+
+	var dashboard grafanaDashboard
+	require.NoError(t, json.Unmarshal(out, &dashboard))
+	require.Equal(t, "Connect server", dashboard.Title)
+	require.Equal(t, []string{
+		"Requests",
+		"Error ratio",
+		"Responses by code",
+		"Duration",
+		"Stream messages sent",
+		"Stream messages received",
+		"Bytes sent",
+		"Bytes received",
+		"Panics",
+	}, panelTitles(dashboard))
+
+	require.Equal(t, `sum by (service, method) (rate(acme_connect_server_handled_total{service=~"$service", method=~"$method"}[$__rate_interval]))`, dashboard.Panels[0].Targets[0].Expr)
+	require.Len(t, dashboard.Panels[3].Targets, 3, "p50, p90 and p99")
+	require.Equal(t, "C", dashboard.Panels[3].Targets[2].RefID)
+	require.Equal(t, grafanaGridPos{H: 8, W: 12, X: 12, Y: 8}, dashboard.Panels[3].GridPos)
+
+	var variables []string
+	for _, variable := range dashboard.Templating.List {
+		variables = append(variables, variable.Name)
+	}
+	require.Equal(t, []string{"datasource", "service", "method"}, variables)
+	require.Equal(t, `label_values(acme_connect_server_handled_total{service=~"$service"}, method)`, dashboard.Templating.List[2].Query)
+}
+
+func TestClientDashboard(t *testing.T) {
+	out, err := ClientDashboard(WithDashboardTitle("Greet client"), WithDashboardMetricsOptions(WithInflightMetrics(true)))
+	require.NoError(t, err)
+
+	var dashboard grafanaDashboard
+	require.NoError(t, json.Unmarshal(out, &dashboard))
+	require.Equal(t, "Greet client", dashboard.Title)
+	require.Equal(t, []string{
+		"Requests",
+		"Error ratio",
+		"Responses by code",
+		"Inflight requests",
+		"Stream messages sent",
+		"Stream messages received",
+	}, panelTitles(dashboard), "panels are only included for the metrics produced")
+}
+
+func TestServerDashboard_GRPCPrometheusNames(t *testing.T) {
+	out, err := ServerDashboard(WithDashboardMetricsOptions(WithGRPCPrometheusNames()))
+	require.NoError(t, err)
+
+	var dashboard grafanaDashboard
+	require.NoError(t, json.Unmarshal(out, &dashboard))
+	require.Equal(t, `sum by (grpc_service, grpc_method) (rate(grpc_server_handled_total{grpc_service=~"$service", grpc_method=~"$method"}[$__rate_interval]))`, dashboard.Panels[0].Targets[0].Expr)
+	require.Equal(t, "{{grpc_service}}/{{grpc_method}}", dashboard.Panels[0].Targets[0].LegendFormat)
+	require.Equal(t, "{{grpc_code}}", dashboard.Panels[2].Targets[0].LegendFormat)
+	require.Contains(t, dashboard.Panels[1].Targets[0].Expr, `grpc_code=~"Unknown|DeadlineExceeded|Internal|Unavailable|DataLoss"`)
+	require.Equal(t, `label_values(grpc_server_handled_total{grpc_service=~"$service"}, grpc_method)`, dashboard.Templating.List[2].Query)
+}
+
+func panelTitles(dashboard grafanaDashboard) []string {
+	var titles []string
+	for _, panel := range dashboard.Panels {
+		titles = append(titles, panel.Title)
+	}
+	return titles
+}
@@ -0,0 +1,74 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	connectTimeoutHeader = "Connect-Timeout-Ms"
+	grpcTimeoutHeader    = "Grpc-Timeout"
+)
+
+// timeoutOf returns how long the caller allowed for the RPC, measured from start. The deadline of ctx
+// takes precedence, falling back to the timeout headers of the Connect and gRPC protocols.
+func timeoutOf(ctx context.Context, header http.Header, start time.Time) (time.Duration, bool) {
+	if deadline, ok := ctx.Deadline(); ok {
+		return deadline.Sub(start), true
+	}
+	if header == nil {
+		return 0, false
+	}
+	if value := header.Get(connectTimeoutHeader); value != "" {
+		if ms, err := strconv.ParseInt(value, 10, 64); err == nil && ms > 0 {
+			return time.Duration(ms) * time.Millisecond, true
+		}
+	}
+	if value := header.Get(grpcTimeoutHeader); value != "" {
+		if timeout, ok := parseGRPCTimeout(value); ok {
+			return timeout, true
+		}
+	}
+	return 0, false
+}
+
+// parseGRPCTimeout parses the value of a grpc-timeout header, a positive integer of at most
+// 8 digits followed by a unit.
+func parseGRPCTimeout(value string) (time.Duration, bool) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, false
+	}
+	var unit time.Duration
+	switch value[len(value)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, false
+	}
+	n, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// deadlineRatio returns the fraction of timeout consumed by elapsed. An already expired
+// deadline is reported as fully consumed.
+func deadlineRatio(elapsed, timeout time.Duration) float64 {
+	if timeout <= 0 {
+		return 1
+	}
+	return float64(elapsed) / float64(timeout)
+}
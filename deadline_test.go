@@ -0,0 +1,53 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeoutOf(t *testing.T) {
+	start := time.Now()
+
+	_, ok := timeoutOf(context.Background(), nil, start)
+	require.False(t, ok)
+
+	ctx, cancel := context.WithDeadline(context.Background(), start.Add(time.Second))
+	defer cancel()
+	timeout, ok := timeoutOf(ctx, http.Header{connectTimeoutHeader: []string{"5000"}}, start)
+	require.True(t, ok)
+	require.Equal(t, time.Second, timeout, "context deadline takes precedence over headers")
+
+	timeout, ok = timeoutOf(context.Background(), http.Header{connectTimeoutHeader: []string{"250"}}, start)
+	require.True(t, ok)
+	require.Equal(t, 250*time.Millisecond, timeout)
+
+	timeout, ok = timeoutOf(context.Background(), http.Header{grpcTimeoutHeader: []string{"3S"}}, start)
+	require.True(t, ok)
+	require.Equal(t, 3*time.Second, timeout)
+
+	_, ok = timeoutOf(context.Background(), http.Header{connectTimeoutHeader: []string{"nope"}}, start)
+	require.False(t, ok)
+}
+
+func TestParseGRPCTimeout(t *testing.T) {
+	for value, expected := range map[string]time.Duration{
+		"1H":        time.Hour,
+		"2M":        2 * time.Minute,
+		"100m":      100 * time.Millisecond,
+		"7u":        7 * time.Microsecond,
+		"99999999n": 99999999 * time.Nanosecond,
+	} {
+		timeout, ok := parseGRPCTimeout(value)
+		require.True(t, ok, value)
+		require.Equal(t, expected, timeout, value)
+	}
+
+	for _, value := range []string{"", "S", "10", "10x", "-1S", "123456789S"} {
+		_, ok := parseGRPCTimeout(value)
+		require.False(t, ok, value)
+	}
+}
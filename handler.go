@@ -0,0 +1,143 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	contentTypeNone  = "none"
+	contentTypeOther = "other"
+)
+
+// knownContentTypes are the content types used by the Connect, gRPC and gRPC-Web protocols. Any other
+// content type is reported as "other" to keep the label values bounded.
+var knownContentTypes = map[string]struct{}{
+	"application/proto":          {},
+	"application/json":           {},
+	"application/connect+proto":  {},
+	"application/connect+json":   {},
+	"application/grpc":           {},
+	"application/grpc+proto":     {},
+	"application/grpc+json":      {},
+	"application/grpc-web":       {},
+	"application/grpc-web+proto": {},
+	"application/grpc-web+json":  {},
+}
+
+type httpRequestKey struct{}
+
+// httpRequest is shared between WrapHandler and the Interceptor through the request context,
+// to reconcile the HTTP requests received with the RPCs intercepted.
+type httpRequest struct {
+	intercepted atomic.Bool
+	procedure   atomic.Value
+}
+
+// markIntercepted records that the RPC for procedure reached the interceptor, when the request
+// was received through a handler wrapped with WrapHandler.
+func markIntercepted(ctx context.Context, procedure string) {
+	if req, ok := ctx.Value(httpRequestKey{}).(*httpRequest); ok {
+		req.procedure.Store(procedure)
+		req.intercepted.Store(true)
+	}
+}
+
+// WrapHandler wraps a http.Handler serving Connect handlers, typically a http.ServeMux, to record
+// the status, content type and procedure of every HTTP request received. Requests rejected by
+// connect-go before reaching the interceptor, such as unknown procedures, unsupported content types
+// or malformed requests, are additionally counted as rejected.
+//
+// Requests are only labelled with the service and method of a procedure which was initialized, or
+// intercepted before, so that arbitrary paths can't create new series. Others are reported with an
+// unknown service and method.
+func WrapHandler(m *Metrics, next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := &httpRequest{}
+		rw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), httpRequestKey{}, req)))
+
+		intercepted := req.intercepted.Load()
+		var service, method string
+		if procedure, ok := req.procedure.Load().(string); ok {
+			service, method = procedureToPackageAndMethod(procedure)
+			m.procedures.Store(service+"/"+method, struct{}{})
+		} else {
+			service, method = procedureFromPath(r.URL.Path)
+			if !m.isKnownProcedure(service, method) {
+				service, method = "unknown", "unknown"
+			}
+		}
+		m.ReportHTTPRequest(service, method, contentTypeOf(r), strconv.Itoa(rw.status), intercepted)
+	})
+}
+
+// isKnownProcedure returns whether the procedure was initialized, or intercepted through WrapHandler.
+func (m *Metrics) isKnownProcedure(service, method string) bool {
+	_, ok := m.procedures.Load(service + "/" + method)
+	return ok
+}
+
+// procedureFromPath returns the service and method from the final two segments of the path.
+func procedureFromPath(path string) (string, string) {
+	path = strings.TrimSuffix(path, "/")
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "unknown", "unknown"
+	}
+	j := strings.LastIndex(path[:i], "/")
+	return procedureToPackageAndMethod(path[j+1:])
+}
+
+func contentTypeOf(r *http.Request) string {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return contentTypeNone
+	}
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	if _, ok := knownContentTypes[contentType]; !ok {
+		return contentTypeOther
+	}
+	return contentType
+}
+
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher, which connect-go requires for streaming RPCs.
+func (w *statusResponseWriter) Flush() {
+	w.wroteHeader = true
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap allows http.ResponseController to access the underlying http.ResponseWriter.
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
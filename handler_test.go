@@ -0,0 +1,107 @@
+package connect_go_prometheus
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapHandler(t *testing.T) {
+	serverMetrics := NewServerMetrics()
+	interceptor := NewInterceptor(WithServerMetrics(serverMetrics), WithClientMetrics(nil))
+
+	mux := http.NewServeMux()
+	mux.Handle(greetconnect.NewGreetServiceHandler(greetconnect.UnimplementedGreetServiceHandler{}, connect.WithInterceptors(interceptor)))
+	srv := httptest.NewServer(WrapHandler(serverMetrics, mux))
+	defer srv.Close()
+
+	createClientAndRequest(t, srv, interceptor)
+	createClientAndStreamRequest(t, srv, interceptor)
+
+	// Unsupported content type, rejected by connect-go before reaching the interceptor.
+	resp, err := http.Post(srv.URL+greetconnect.GreetServiceGreetProcedure, "text/plain", bytes.NewReader(nil))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+
+	// Unknown procedure.
+	resp, err = http.Post(srv.URL+"/greet.v1.GreetService/Unknown", "application/proto", bytes.NewReader(nil))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	require.EqualValues(t, 1, testutil.ToFloat64(serverMetrics.httpRequests.WithLabelValues(greetconnect.GreetServiceName, "Greet", "application/proto", "404")))
+	require.EqualValues(t, 1, testutil.ToFloat64(serverMetrics.httpRequests.WithLabelValues(greetconnect.GreetServiceName, "ServerStreamGreet", "application/connect+proto", "200")))
+	require.EqualValues(t, 1, testutil.ToFloat64(serverMetrics.httpRequests.WithLabelValues(greetconnect.GreetServiceName, "Greet", contentTypeOther, "415")))
+	require.EqualValues(t, 1, testutil.ToFloat64(serverMetrics.httpRequests.WithLabelValues("unknown", "unknown", "application/proto", "404")))
+	require.Equal(t, 4, testutil.CollectAndCount(serverMetrics.httpRequests))
+
+	require.EqualValues(t, 1, testutil.ToFloat64(serverMetrics.httpRejected.WithLabelValues(greetconnect.GreetServiceName, "Greet", contentTypeOther, "415")))
+	require.EqualValues(t, 1, testutil.ToFloat64(serverMetrics.httpRejected.WithLabelValues("unknown", "unknown", "application/proto", "404")))
+	require.Equal(t, 2, testutil.CollectAndCount(serverMetrics.httpRejected))
+}
+
+func TestWrapHandler_RandomPaths(t *testing.T) {
+	serverMetrics := NewServerMetrics()
+	interceptor := NewInterceptor(WithServerMetrics(serverMetrics), WithClientMetrics(nil))
+
+	mux := http.NewServeMux()
+	mux.Handle(greetconnect.NewGreetServiceHandler(greetconnect.UnimplementedGreetServiceHandler{}, connect.WithInterceptors(interceptor)))
+	mux.HandleFunc("/admin/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	srv := httptest.NewServer(WrapHandler(serverMetrics, mux))
+	defer srv.Close()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	for i := 0; i < 20; i++ {
+		for _, path := range []string{
+			fmt.Sprintf("/admin/svc%d/Method%d", i, i),
+			fmt.Sprintf("//a%d/b%d", i, i),
+			fmt.Sprintf("/x%d/y%d", i, i),
+		} {
+			resp, err := client.Post(srv.URL+path, "application/proto", bytes.NewReader(nil))
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+		}
+	}
+
+	// Paths of procedures which weren't intercepted are labelled only once initialized.
+	resp, err := client.Post(srv.URL+greetconnect.GreetServiceGreetProcedure, "text/plain", bytes.NewReader(nil))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	serverMetrics.Initialize("unary", greetconnect.GreetServiceName, "Greet")
+	resp, err = client.Post(srv.URL+greetconnect.GreetServiceGreetProcedure, "text/plain", bytes.NewReader(nil))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	require.EqualValues(t, 20, testutil.ToFloat64(serverMetrics.httpRequests.WithLabelValues("unknown", "unknown", "application/proto", "401")))
+	require.EqualValues(t, 20, testutil.ToFloat64(serverMetrics.httpRequests.WithLabelValues("unknown", "unknown", "application/proto", "301")))
+	require.EqualValues(t, 20, testutil.ToFloat64(serverMetrics.httpRequests.WithLabelValues("unknown", "unknown", "application/proto", "404")))
+	require.EqualValues(t, 1, testutil.ToFloat64(serverMetrics.httpRequests.WithLabelValues("unknown", "unknown", contentTypeOther, "415")))
+	require.EqualValues(t, 1, testutil.ToFloat64(serverMetrics.httpRequests.WithLabelValues(greetconnect.GreetServiceName, "Greet", contentTypeOther, "415")))
+	require.Equal(t, 5, testutil.CollectAndCount(serverMetrics.httpRequests))
+}
+
+func TestProcedureFromPath(t *testing.T) {
+	service, method := procedureFromPath("/greet.v1.GreetService/Greet")
+	require.Equal(t, greetconnect.GreetServiceName, service)
+	require.Equal(t, "Greet", method)
+
+	service, method = procedureFromPath("/api/greet.v1.GreetService/Greet")
+	require.Equal(t, greetconnect.GreetServiceName, service)
+	require.Equal(t, "Greet", method)
+
+	service, method = procedureFromPath("/")
+	require.Equal(t, "unknown", service)
+	require.Equal(t, "unknown", method)
+}
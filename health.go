@@ -0,0 +1,225 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/grpchealth"
+)
+
+const (
+	// DefaultHealthErrorThreshold marks a service as not serving once over 25% of its calls fail.
+	DefaultHealthErrorThreshold = 0.25
+	// DefaultHealthWindow is the period over which the error ratio of a service is computed.
+	DefaultHealthWindow = time.Minute
+	// DefaultHealthMinCalls is the number of calls within the window below which a service is always serving.
+	DefaultHealthMinCalls = 20
+)
+
+// NewHealth creates a grpchealth.Checker which maintains the rolling error ratio of the calls handled by
+// each service, and marks a service as not serving while its error ratio exceeds the threshold. Use it
+// with WithHealth, and serve it with grpchealth.NewHandler.
+//
+// Only server-side calls are observed, and only the codes configured with WithHealthErrorCodes count
+// as errors, so that client mistakes such as invalid arguments don't degrade the server's health.
+func NewHealth(opts ...HealthOption) *Health {
+	options := evaluateHealthOptions(&healthOptions{
+		threshold:  DefaultHealthErrorThreshold,
+		window:     DefaultHealthWindow,
+		minCalls:   DefaultHealthMinCalls,
+		errorCodes: serverErrorCodes(),
+	}, opts...)
+
+	services := make(map[string]*serviceHealth, len(options.services))
+	for _, service := range options.services {
+		services[service] = newServiceHealth(options.window)
+	}
+
+	errorCodes := make(map[string]struct{}, len(options.errorCodes))
+	for _, code := range options.errorCodes {
+		errorCodes[code.String()] = struct{}{}
+	}
+
+	return &Health{
+		threshold:  options.threshold,
+		window:     options.window,
+		minCalls:   options.minCalls,
+		errorCodes: errorCodes,
+		callback:   options.callback,
+		now:        time.Now,
+		services:   services,
+	}
+}
+
+var (
+	_ callObserver       = (*Health)(nil)
+	_ grpchealth.Checker = (*Health)(nil)
+)
+
+type Health struct {
+	threshold  float64
+	window     time.Duration
+	minCalls   int
+	errorCodes map[string]struct{}
+	callback   func(service string, status grpchealth.Status)
+	now        func() time.Time
+
+	mu       sync.Mutex
+	services map[string]*serviceHealth
+}
+
+type serviceHealth struct {
+	calls  *rollingCounts
+	status grpchealth.Status
+}
+
+func newServiceHealth(window time.Duration) *serviceHealth {
+	return &serviceHealth{calls: newRollingCounts(window), status: grpchealth.StatusServing}
+}
+
+// Check implements grpchealth.Checker, following the grpc.health.v1 semantics: an empty service is the
+// status of the whole server, which is not serving as soon as any of its services is not serving. Services
+// which were neither configured WithHealthServices nor handled any calls are not found.
+func (h *Health) Check(_ context.Context, req *grpchealth.CheckRequest) (*grpchealth.CheckResponse, error) {
+	now := h.now()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if req.Service != "" {
+		s, ok := h.services[req.Service]
+		if !ok {
+			return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("unknown service %s", req.Service))
+		}
+		return &grpchealth.CheckResponse{Status: h.statusOf(s, now)}, nil
+	}
+	for _, s := range h.services {
+		if h.statusOf(s, now) == grpchealth.StatusNotServing {
+			return &grpchealth.CheckResponse{Status: grpchealth.StatusNotServing}, nil
+		}
+	}
+	return &grpchealth.CheckResponse{Status: grpchealth.StatusServing}, nil
+}
+
+// ErrorRatio returns the ratio of the calls handled by service within the window which failed.
+func (h *Health) ErrorRatio(service string) float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.services[service]
+	if !ok {
+		return 0
+	}
+	calls, failed := s.calls.counts(h.now())
+	if calls == 0 {
+		return 0
+	}
+	return float64(failed) / float64(calls)
+}
+
+func (h *Health) callStarted(context.Context, *call) {}
+
+func (h *Health) callFinished(_ context.Context, c *call) {
+	if c.isClient {
+		return
+	}
+	_, failed := h.errorCodes[c.code]
+	now := h.now()
+
+	h.mu.Lock()
+	s, ok := h.services[c.service]
+	if !ok {
+		s = newServiceHealth(h.window)
+		h.services[c.service] = s
+	}
+	s.calls.add(now, failed)
+	previous := s.status
+	s.status = h.statusOf(s, now)
+	changed := s.status != previous
+	status := s.status
+	h.mu.Unlock()
+
+	if changed && h.callback != nil {
+		h.callback(c.service, status)
+	}
+}
+
+// statusOf returns the status of s from its calls within the window. It must be called with h.mu held.
+func (h *Health) statusOf(s *serviceHealth, now time.Time) grpchealth.Status {
+	calls, failed := s.calls.counts(now)
+	if calls == 0 || calls < h.minCalls {
+		return grpchealth.StatusServing
+	}
+	if float64(failed)/float64(calls) > h.threshold {
+		return grpchealth.StatusNotServing
+	}
+	return grpchealth.StatusServing
+}
+
+type healthOptions struct {
+	threshold  float64
+	window     time.Duration
+	minCalls   int
+	errorCodes []connect.Code
+	services   []string
+	callback   func(service string, status grpchealth.Status)
+}
+
+type HealthOption func(*healthOptions)
+
+// WithHealthErrorThreshold configures the error ratio, between 0 and 1, above which a service is not
+// serving, see DefaultHealthErrorThreshold.
+func WithHealthErrorThreshold(threshold float64) HealthOption {
+	return func(opts *healthOptions) {
+		opts.threshold = threshold
+	}
+}
+
+// WithHealthWindow configures the period over which the error ratio is computed, see DefaultHealthWindow.
+func WithHealthWindow(window time.Duration) HealthOption {
+	return func(opts *healthOptions) {
+		opts.window = window
+	}
+}
+
+// WithHealthMinCalls configures the number of calls within the window below which a service is always
+// serving, so that a few failures on a quiet service don't degrade its health, see DefaultHealthMinCalls.
+func WithHealthMinCalls(n int) HealthOption {
+	return func(opts *healthOptions) {
+		opts.minCalls = n
+	}
+}
+
+// WithHealthErrorCodes configures the codes which count as errors. Defaults to unknown, deadline_exceeded,
+// internal, unavailable and data_loss.
+func WithHealthErrorCodes(codes ...connect.Code) HealthOption {
+	return func(opts *healthOptions) {
+		opts.errorCodes = codes
+	}
+}
+
+// WithHealthServices configures the services known before they handle any calls, which are serving
+// rather than not found, such as the names of the services registered with the server.
+func WithHealthServices(services ...string) HealthOption {
+	return func(opts *healthOptions) {
+		opts.services = append(opts.services, services...)
+	}
+}
+
+// WithHealthCallback configures a function called with the new status of a service whenever it changes.
+// Changes are detected as calls complete, so a service recovers once it handles calls again.
+func WithHealthCallback(callback func(service string, status grpchealth.Status)) HealthOption {
+	return func(opts *healthOptions) {
+		opts.callback = callback
+	}
+}
+
+func evaluateHealthOptions(defaults *healthOptions, opts ...HealthOption) *healthOptions {
+	for _, opt := range opts {
+		opt(defaults)
+	}
+	return defaults
+}
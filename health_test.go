@@ -0,0 +1,107 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/grpchealth"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	"github.com/stretchr/testify/require"
+)
+
+func checkHealth(t *testing.T, health *Health, service string) grpchealth.Status {
+	t.Helper()
+	resp, err := health.Check(context.Background(), &grpchealth.CheckRequest{Service: service})
+	require.NoError(t, err)
+	return resp.Status
+}
+
+func TestHealth(t *testing.T) {
+	var changes []grpchealth.Status
+	health := NewHealth(
+		WithHealthMinCalls(2),
+		WithHealthErrorThreshold(0.5),
+		WithHealthServices(greetconnect.GreetServiceName),
+		WithHealthCallback(func(service string, status grpchealth.Status) {
+			require.Equal(t, greetconnect.GreetServiceName, service)
+			changes = append(changes, status)
+		}),
+	)
+	interceptor := NewInterceptor(WithClientMetrics(nil), WithServerMetrics(nil), WithHealth(health))
+
+	mux := http.NewServeMux()
+	mux.Handle(greetconnect.NewGreetServiceHandler(unavailableGreetServiceHandler{}, connect.WithInterceptors(interceptor)))
+	mux.Handle(grpchealth.NewHandler(health))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	require.Equal(t, grpchealth.StatusServing, checkHealth(t, health, ""))
+	require.Equal(t, grpchealth.StatusServing, checkHealth(t, health, greetconnect.GreetServiceName), "configured services are serving before handling calls")
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, connect.WithInterceptors(interceptor))
+	for i := 0; i < 2; i++ {
+		_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "eliza"}))
+		require.Equal(t, connect.CodeUnavailable, connect.CodeOf(err))
+	}
+
+	require.EqualValues(t, 1, health.ErrorRatio(greetconnect.GreetServiceName), "client-side calls are not observed")
+	require.Equal(t, grpchealth.StatusNotServing, checkHealth(t, health, greetconnect.GreetServiceName))
+	require.Equal(t, grpchealth.StatusNotServing, checkHealth(t, health, ""))
+	require.Equal(t, []grpchealth.Status{grpchealth.StatusNotServing}, changes)
+
+	_, err := health.Check(context.Background(), &grpchealth.CheckRequest{Service: "other.v1.OtherService"})
+	require.Equal(t, connect.CodeNotFound, connect.CodeOf(err), "unknown services are not found")
+
+	// Served with grpchealth.NewHandler, over the Connect protocol.
+	check := func(service string) (*http.Response, map[string]string) {
+		resp, err := http.Post(srv.URL+"/grpc.health.v1.Health/Check", "application/json", strings.NewReader(`{"service":"`+service+`"}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp, body
+	}
+	resp, body := check(greetconnect.GreetServiceName)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "SERVING_STATUS_NOT_SERVING", body["status"])
+	resp, body = check("other.v1.OtherService")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.Equal(t, connect.CodeNotFound.String(), body["code"])
+}
+
+func TestHealth_Window(t *testing.T) {
+	now := time.Unix(1000, 0)
+	var changes []grpchealth.Status
+	health := NewHealth(
+		WithHealthWindow(time.Minute),
+		WithHealthMinCalls(4),
+		WithHealthCallback(func(_ string, status grpchealth.Status) {
+			changes = append(changes, status)
+		}),
+	)
+	health.now = func() time.Time { return now }
+
+	finish := func(code string) {
+		health.callFinished(context.Background(), &call{service: "test.v1.TestService", code: code})
+	}
+
+	for i := 0; i < 3; i++ {
+		finish(connect.CodeInternal.String())
+	}
+	require.Equal(t, grpchealth.StatusServing, checkHealth(t, health, ""), "below the minimum number of calls")
+	finish(connect.CodeInvalidArgument.String())
+	require.InDelta(t, 0.75, health.ErrorRatio("test.v1.TestService"), 0.0001, "invalid arguments are not errors")
+	require.Equal(t, grpchealth.StatusNotServing, checkHealth(t, health, "test.v1.TestService"))
+
+	now = now.Add(2 * time.Minute)
+	require.Equal(t, grpchealth.StatusServing, checkHealth(t, health, "test.v1.TestService"), "errors expired")
+	finish(CodeOk)
+	require.Equal(t, []grpchealth.Status{grpchealth.StatusNotServing, grpchealth.StatusServing}, changes)
+}
@@ -2,19 +2,55 @@ package connect_go_prometheus
 
 import (
 	"context"
+	"fmt"
 	"strings"
-	"time"
 
 	"connectrpc.com/connect"
 	"github.com/cockroachdb/errors"
-	prom "github.com/prometheus/client_golang/prometheus"
-	"google.golang.org/protobuf/proto"
 )
 
 const (
 	CodeOk = "ok"
 )
 
+const (
+	// CancelSourceClientDisconnect indicates the client went away, or canceled the RPC, before it completed.
+	CancelSourceClientDisconnect = "client_disconnect"
+	// CancelSourceDeadline indicates the deadline of the incoming request expired before the RPC completed.
+	CancelSourceDeadline = "deadline"
+	// CancelSourceHandler indicates the handler returned a cancellation while the request was still live,
+	// typically because a downstream call it made was canceled or timed out.
+	CancelSourceHandler = "handler"
+)
+
+// codes contains every value the interceptor reports in the code label.
+var codes = []string{
+	CodeOk,
+	connect.CodeCanceled.String(),
+	connect.CodeUnknown.String(),
+	connect.CodeInvalidArgument.String(),
+	connect.CodeDeadlineExceeded.String(),
+	connect.CodeNotFound.String(),
+	connect.CodeAlreadyExists.String(),
+	connect.CodePermissionDenied.String(),
+	connect.CodeResourceExhausted.String(),
+	connect.CodeFailedPrecondition.String(),
+	connect.CodeAborted.String(),
+	connect.CodeOutOfRange.String(),
+	connect.CodeUnimplemented.String(),
+	connect.CodeInternal.String(),
+	connect.CodeUnavailable.String(),
+	connect.CodeDataLoss.String(),
+	connect.CodeUnauthenticated.String(),
+}
+
+// Codes returns the complete set of values reported in the code label: CodeOk for successful RPCs,
+// followed by every connect.Code. Errors without a recognized code are reported as unknown, and
+// panics are reported as internal. The returned slice is a copy and may be modified by the caller.
+func Codes() []string {
+	return append([]string(nil), codes...)
+}
+
 func NewInterceptor(opts ...InterceptorOption) *Interceptor {
 	options := evaluteInterceptorOptions(&interceptorOptions{
 		client: DefaultClientMetrics,
@@ -22,65 +58,60 @@ func NewInterceptor(opts ...InterceptorOption) *Interceptor {
 	}, opts...)
 
 	return &Interceptor{
-		client: options.client,
-		server: options.server,
+		client:        options.client,
+		server:        options.server,
+		observers:     options.observers,
+		recoverPanics: options.recoverPanics,
 	}
 }
 
 var _ connect.Interceptor = (*Interceptor)(nil)
 
 type Interceptor struct {
-	client *Metrics
-	server *Metrics
+	client        Recorder
+	server        Recorder
+	observers     []callObserver
+	recoverPanics bool
 }
 
 func (i *Interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
-	return connect.UnaryFunc(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
-		// Short-circuit, not configured to report for either client or server.
-		if i.client == nil && i.server == nil {
+	return connect.UnaryFunc(func(ctx context.Context, req connect.AnyRequest) (resp connect.AnyResponse, err error) {
+		isClient := req.Spec().IsClient
+		if !isClient {
+			markIntercepted(ctx, req.Spec().Procedure)
+		}
+
+		reporter := i.reporterFor(isClient)
+		// Short-circuit, not configured to report for this side of the call, nor to recover its panics.
+		if reporter == nil && len(i.observers) == 0 && !i.recovers(isClient) {
 			return next(ctx, req)
 		}
 
-		now := time.Now()
-		callType := streamTypeString(req.Spec().StreamType)
-		callPackage, callMethod := procedureToPackageAndMethod(req.Spec().Procedure)
+		c := newCall(req.Spec(), req.Peer(), req.Header(), reporter, i.observers)
+		if isClient {
+			ctx = withCallType(ctx, c.callType)
+		}
+		c.begin(ctx)
+		defer func() {
+			r := recover()
+			c.finish(ctx, err, r != nil)
+			if r != nil {
+				resp, err = nil, i.handlePanic(r, isClient)
+			}
+		}()
 
-		var reporter *Metrics
-		if req.Spec().IsClient {
-			reporter = i.client
+		if isClient {
+			c.sent(req.Any(), false)
 		} else {
-			reporter = i.server
+			c.received(req.Any(), false)
 		}
 
-		var code string
-		if reporter != nil {
-			var bytes *prom.CounterVec
-			if reporter.isClient {
-				bytes = reporter.bytesSent
+		resp, err = next(ctx, req)
+		if err == nil {
+			if isClient {
+				c.received(resp.Any(), false)
 			} else {
-				bytes = reporter.bytesReceived
-			}
-			if bytes != nil {
-				bytes.WithLabelValues(callType, callPackage, callMethod).Add(float64(proto.Size(req.Any().(proto.Message))))
-			}
-			reporter.ReportStarted(callType, callPackage, callMethod)
-			defer func() {
-				reporter.ReportHandled(callType, callPackage, callMethod, code)
-				reporter.ReportHandledSeconds(callType, callPackage, callMethod, code, time.Since(now).Seconds())
-			}()
-		}
-
-		resp, err := next(ctx, req)
-		code = codeOf(err)
-		if err == nil && reporter != nil {
-			var bytes *prom.CounterVec
-			if reporter.isClient {
-				bytes = reporter.bytesReceived
-			} else {
-				bytes = reporter.bytesSent
-			}
-			if bytes != nil {
-				bytes.WithLabelValues(callType, callPackage, callMethod).Add(float64(proto.Size(resp.Any().(proto.Message))))
+				c.sent(resp.Any(), false)
 			}
 		}
 
@@ -91,51 +122,62 @@ func (i *Interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
 func (i *Interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
 	return connect.StreamingClientFunc(func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
 		// Short-circuit, not configured to report for client.
-		if i.client == nil {
+		if i.client == nil && len(i.observers) == 0 {
 			return next(ctx, spec)
 		}
 
-		now := time.Now()
-		callType := streamTypeString(spec.StreamType)
-		callPackage, callMethod := procedureToPackageAndMethod(spec.Procedure)
-
-		i.client.ReportStarted(callType, callPackage, callMethod)
-		onClose := func(err error) {
-			code := codeOf(err)
-			i.client.ReportHandled(callType, callPackage, callMethod, code)
-			i.client.ReportHandledSeconds(callType, callPackage, callMethod, code, time.Since(now).Seconds())
-		}
-
-		conn := next(ctx, spec)
-		return newStreamingClientConn(conn, i, onClose)
+		conn := next(withCallType(ctx, streamTypeString(spec.StreamType)), spec)
+		c := newCall(spec, conn.Peer(), conn.RequestHeader(), i.client, i.observers)
+		c.begin(ctx)
+		return newStreamingClientConn(ctx, conn, c)
 	})
 }
 
 func (i *Interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
-	return connect.StreamingHandlerFunc(func(ctx context.Context, shc connect.StreamingHandlerConn) error {
-		// Short-circuit, not configured to report for server.
-		if i.server == nil {
+	return connect.StreamingHandlerFunc(func(ctx context.Context, shc connect.StreamingHandlerConn) (err error) {
+		markIntercepted(ctx, shc.Spec().Procedure)
+
+		// Short-circuit, not configured to report for server, nor to recover its panics.
+		if i.server == nil && len(i.observers) == 0 && !i.recovers(false) {
 			return next(ctx, shc)
 		}
 
-		now := time.Now()
-		callType := streamTypeString(shc.Spec().StreamType)
-		callPackage, callMethod := procedureToPackageAndMethod(shc.Spec().Procedure)
-
-		var code string
-		i.server.ReportStarted(callType, callPackage, callMethod)
+		c := newCall(shc.Spec(), shc.Peer(), shc.RequestHeader(), i.server, i.observers)
+		c.begin(ctx)
 		defer func() {
-			i.server.ReportHandled(callType, callPackage, callMethod, code)
-			i.server.ReportHandledSeconds(callType, callPackage, callMethod, code, time.Since(now).Seconds())
+			r := recover()
+			c.finish(ctx, err, r != nil)
+			if r != nil {
+				err = i.handlePanic(r, false)
+			}
 		}()
 
-		shc = newStreamingHandlerConn(shc, i)
-		err := next(ctx, shc)
-		code = codeOf(err)
-		return err
+		return next(ctx, newStreamingHandlerConn(shc, c))
 	})
 }
 
+// reporterFor returns the Recorder for the client or server side of a call.
+func (i *Interceptor) reporterFor(isClient bool) Recorder {
+	if isClient {
+		return i.client
+	}
+	return i.server
+}
+
+// recovers returns whether panics are recovered for the client or server side of a call.
+func (i *Interceptor) recovers(isClient bool) bool {
+	return !isClient && i.recoverPanics
+}
+
+// handlePanic re-panics with the recovered value, unless panic recovery is enabled
+// for server-side calls, in which case the panic is converted into an internal error.
+func (i *Interceptor) handlePanic(r any, isClient bool) error {
+	if !i.recovers(isClient) {
+		panic(r)
+	}
+	return connect.NewError(connect.CodeInternal, fmt.Errorf("panic: %v", r))
+}
+
 func procedureToPackageAndMethod(procedure string) (string, string) {
 	procedure = strings.TrimPrefix(procedure, "/") // remove leading slash
 	if i := strings.Index(procedure, "/"); i >= 0 {
@@ -172,25 +214,117 @@ func codeOf(err error) string {
 			code = connect.CodeDeadlineExceeded
 		}
 	}
+	if code < connect.CodeCanceled || code > connect.CodeUnauthenticated {
+		// Not a code connect-go defines, report it as unknown to keep the label values bounded.
+		code = connect.CodeUnknown
+	}
 	return code.String()
 }
 
+// cancelSourceOf determines why a server-side RPC completed with a canceled or deadline_exceeded
+// code by inspecting the handler context. It returns false for any other code.
+func cancelSourceOf(ctx context.Context, code string) (string, bool) {
+	if code != connect.CodeCanceled.String() && code != connect.CodeDeadlineExceeded.String() {
+		return "", false
+	}
+	switch ctxErr := ctx.Err(); {
+	case errors.Is(ctxErr, context.DeadlineExceeded):
+		return CancelSourceDeadline, true
+	case errors.Is(ctxErr, context.Canceled):
+		return CancelSourceClientDisconnect, true
+	default:
+		return CancelSourceHandler, true
+	}
+}
+
 type interceptorOptions struct {
-	client *Metrics
-	server *Metrics
+	client        Recorder
+	server        Recorder
+	observers     []callObserver
+	recoverPanics bool
 }
 
 type InterceptorOption func(*interceptorOptions)
 
 func WithClientMetrics(m *Metrics) InterceptorOption {
-	return func(io *interceptorOptions) {
-		io.client = m
+	if m == nil {
+		return WithClientRecorder(nil)
 	}
+	return WithClientRecorder(m)
 }
 
 func WithServerMetrics(m *Metrics) InterceptorOption {
+	if m == nil {
+		return WithServerRecorder(nil)
+	}
+	return WithServerRecorder(m)
+}
+
+// WithClientRecorder configures the interceptor to report client-side RPCs to r, instead of
+// DefaultClientMetrics. Pass nil to disable reporting of client-side RPCs.
+func WithClientRecorder(r Recorder) InterceptorOption {
+	return func(io *interceptorOptions) {
+		io.client = r
+	}
+}
+
+// WithServerRecorder configures the interceptor to report server-side RPCs to r, instead of
+// DefaultServerMetrics. Pass nil to disable reporting of server-side RPCs.
+func WithServerRecorder(r Recorder) InterceptorOption {
+	return func(io *interceptorOptions) {
+		io.server = r
+	}
+}
+
+// WithPanicRecovery configures the interceptor to recover from panics in server-side handlers
+// and return a connect.CodeInternal error instead. Panics are always reported as an internal code,
+// and counted in the server panics metric, regardless of whether recovery is enabled.
+func WithPanicRecovery(enabled bool) InterceptorOption {
+	return func(io *interceptorOptions) {
+		io.recoverPanics = enabled
+	}
+}
+
+// WithAccessLogger configures the interceptor to write an access log record for every completed
+// client-side and server-side RPC to l.
+func WithAccessLogger(l *AccessLogger) InterceptorOption {
+	return withObserver(l)
+}
+
+// WithSlowCallLogger configures the interceptor to log client-side and server-side RPCs exceeding
+// the thresholds of l.
+func WithSlowCallLogger(l *SlowCallLogger) InterceptorOption {
+	return withObserver(l)
+}
+
+// WithTracker configures the interceptor to track the client-side and server-side RPCs in flight in t.
+func WithTracker(t *Tracker) InterceptorOption {
+	return withObserver(t)
+}
+
+// WithRecentErrors configures the interceptor to keep the recent failed client-side and server-side RPCs in e.
+func WithRecentErrors(e *RecentErrors) InterceptorOption {
+	return withObserver(e)
+}
+
+// WithSlowestCalls configures the interceptor to keep the slowest client-side and server-side RPCs in s.
+func WithSlowestCalls(s *SlowestCalls) InterceptorOption {
+	return withObserver(s)
+}
+
+// WithLatencyQuantiles configures the interceptor to feed the latency of client-side and server-side RPCs to l.
+func WithLatencyQuantiles(l *LatencyQuantiles) InterceptorOption {
+	return withObserver(l)
+}
+
+// WithHealth configures the interceptor to report the completion of server-side RPCs to h.
+func WithHealth(h *Health) InterceptorOption {
+	return withObserver(h)
+}
+
+func withObserver(o callObserver) InterceptorOption {
 	return func(io *interceptorOptions) {
-		io.server = m
+		io.observers = append(io.observers, o)
 	}
 }
 
@@ -5,8 +5,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"connectrpc.com/connect"
+	"github.com/cockroachdb/errors"
 	"github.com/easyCZ/connect-go-prometheus/gen/greet"
 	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
 	prom "github.com/prometheus/client_golang/prometheus"
@@ -234,3 +236,178 @@ func TestInterceptor_WithServerMetrics(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, 6, count, "must report only server-side metrics, client-side is disabled")
 }
+
+type panickingGreetServiceHandler struct {
+	greetconnect.UnimplementedGreetServiceHandler
+}
+
+func (panickingGreetServiceHandler) Greet(context.Context, *connect.Request[greet.GreetRequest]) (*connect.Response[greet.GreetResponse], error) {
+	panic("boom")
+}
+
+func TestInterceptor_WithPanicRecovery(t *testing.T) {
+	reg := prom.NewRegistry()
+	serverMetrics := NewServerMetrics()
+	require.NoError(t, reg.Register(serverMetrics))
+
+	interceptor := NewInterceptor(WithServerMetrics(serverMetrics), WithClientMetrics(nil), WithPanicRecovery(true))
+
+	_, handler := greetconnect.NewGreetServiceHandler(panickingGreetServiceHandler{}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL)
+	_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "eliza"}))
+	require.Error(t, err)
+	require.Equal(t, connect.CodeInternal, connect.CodeOf(err))
+
+	require.EqualValues(t, 1, testutil.ToFloat64(serverMetrics.panics.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet")))
+	require.EqualValues(t, 1, testutil.ToFloat64(serverMetrics.requestHandled.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet", connect.CodeInternal.String())))
+}
+
+// specHandlerConn is a streaming handler connection with only a spec, peer and request header.
+type specHandlerConn struct {
+	connect.StreamingHandlerConn
+	spec connect.Spec
+}
+
+func (c specHandlerConn) Spec() connect.Spec         { return c.spec }
+func (c specHandlerConn) Peer() connect.Peer         { return connect.Peer{} }
+func (c specHandlerConn) RequestHeader() http.Header { return http.Header{} }
+
+func TestInterceptor_WithPanicRecoveryWithoutRecorders(t *testing.T) {
+	interceptor := NewInterceptor(WithServerMetrics(nil), WithClientMetrics(nil), WithPanicRecovery(true))
+
+	_, handler := greetconnect.NewGreetServiceHandler(panickingGreetServiceHandler{}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL)
+	_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "eliza"}))
+	require.Equal(t, connect.CodeInternal, connect.CodeOf(err), "unary panics are recovered without recorders")
+
+	streaming := interceptor.WrapStreamingHandler(func(context.Context, connect.StreamingHandlerConn) error {
+		panic("boom")
+	})
+	err = streaming(context.Background(), specHandlerConn{spec: connect.Spec{
+		StreamType: connect.StreamTypeBidi,
+		Procedure:  "/greet.v1.GreetService/Greet",
+	}})
+	require.Equal(t, connect.CodeInternal, connect.CodeOf(err), "streaming panics are recovered without recorders")
+}
+
+func TestInterceptor_GRPCPrometheusNamesUnaryMessages(t *testing.T) {
+	serverMetrics := NewServerMetrics(WithGRPCPrometheusNames())
+	clientMetrics := NewClientMetrics(WithGRPCPrometheusNames())
+	interceptor := NewInterceptor(WithServerMetrics(serverMetrics), WithClientMetrics(clientMetrics))
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetconnect.UnimplementedGreetServiceHandler{}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	createClientAndRequest(t, srv, interceptor)
+
+	require.EqualValues(t, 1, testutil.ToFloat64(serverMetrics.streamMsgReceived.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet")), "unary requests are counted as go-grpc-prometheus does")
+	require.EqualValues(t, 1, testutil.ToFloat64(clientMetrics.streamMsgSent.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet")))
+	require.Equal(t, 0, testutil.CollectAndCount(serverMetrics.streamMsgSent), "no response is sent for failed calls")
+
+	defaultMetrics := NewServerMetrics()
+	interceptor = NewInterceptor(WithServerMetrics(defaultMetrics), WithClientMetrics(nil))
+	_, handler = greetconnect.NewGreetServiceHandler(greetconnect.UnimplementedGreetServiceHandler{}, connect.WithInterceptors(interceptor))
+	srv2 := httptest.NewServer(handler)
+	defer srv2.Close()
+	createClientAndRequest(t, srv2, interceptor)
+	require.Equal(t, 0, testutil.CollectAndCount(defaultMetrics.streamMsgReceived), "unary messages are not counted by default")
+}
+
+func TestInterceptor_PanicWithoutRecovery(t *testing.T) {
+	serverMetrics := NewServerMetrics()
+	interceptor := NewInterceptor(WithServerMetrics(serverMetrics), WithClientMetrics(nil))
+
+	unary := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		panic("boom")
+	})
+	require.PanicsWithValue(t, "boom", func() {
+		_, _ = unary(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "eliza"}))
+	})
+
+	require.EqualValues(t, 1, testutil.ToFloat64(serverMetrics.panics.WithLabelValues("unary", "unknown", "unknown")))
+	require.EqualValues(t, 1, testutil.ToFloat64(serverMetrics.requestHandled.WithLabelValues("unary", "unknown", "unknown", connect.CodeInternal.String())))
+}
+
+func TestCodeOf(t *testing.T) {
+	for _, c := range []connect.Code{
+		connect.CodeCanceled, connect.CodeUnknown, connect.CodeInvalidArgument, connect.CodeDeadlineExceeded,
+		connect.CodeNotFound, connect.CodeAlreadyExists, connect.CodePermissionDenied, connect.CodeResourceExhausted,
+		connect.CodeFailedPrecondition, connect.CodeAborted, connect.CodeOutOfRange, connect.CodeUnimplemented,
+		connect.CodeInternal, connect.CodeUnavailable, connect.CodeDataLoss, connect.CodeUnauthenticated,
+	} {
+		code := codeOf(connect.NewError(c, errors.New("oops")))
+		require.Equal(t, c.String(), code)
+		require.Contains(t, Codes(), code)
+	}
+
+	require.Equal(t, CodeOk, codeOf(nil))
+	require.Equal(t, connect.CodeCanceled.String(), codeOf(context.Canceled))
+	require.Equal(t, connect.CodeDeadlineExceeded.String(), codeOf(context.DeadlineExceeded))
+	require.Equal(t, connect.CodeUnknown.String(), codeOf(errors.New("oops")))
+	require.Equal(t, connect.CodeUnknown.String(), codeOf(connect.NewError(connect.Code(99), errors.New("oops"))))
+}
+
+func TestInterceptor_WithCancelSourceMetrics(t *testing.T) {
+	serverMetrics := NewServerMetrics(WithCancelSourceMetrics(true))
+	interceptor := NewInterceptor(WithServerMetrics(serverMetrics), WithClientMetrics(nil))
+
+	unary := interceptor.WrapUnary(func(ctx context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := unary(ctx, connect.NewRequest(&greet.GreetRequest{}))
+	require.ErrorIs(t, err, context.Canceled)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = unary(ctx, connect.NewRequest(&greet.GreetRequest{}))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.EqualValues(t, 1, testutil.ToFloat64(serverMetrics.canceled.WithLabelValues("unary", "unknown", "unknown", connect.CodeCanceled.String(), CancelSourceClientDisconnect)))
+	require.EqualValues(t, 1, testutil.ToFloat64(serverMetrics.canceled.WithLabelValues("unary", "unknown", "unknown", connect.CodeDeadlineExceeded.String(), CancelSourceDeadline)))
+}
+
+func TestCancelSourceOf(t *testing.T) {
+	_, ok := cancelSourceOf(context.Background(), CodeOk)
+	require.False(t, ok)
+
+	source, ok := cancelSourceOf(context.Background(), connect.CodeDeadlineExceeded.String())
+	require.True(t, ok)
+	require.Equal(t, CancelSourceHandler, source)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	source, ok = cancelSourceOf(ctx, connect.CodeCanceled.String())
+	require.True(t, ok)
+	require.Equal(t, CancelSourceClientDisconnect, source)
+}
+
+func TestInterceptor_WithDeadlineMetrics(t *testing.T) {
+	serverMetrics := NewServerMetrics(WithDeadlineMetrics(true))
+	interceptor := NewInterceptor(WithServerMetrics(serverMetrics), WithClientMetrics(nil))
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetconnect.UnimplementedGreetServiceHandler{}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	createClientAndRequest(t, srv, interceptor)
+	require.EqualValues(t, 1, testutil.ToFloat64(serverMetrics.noDeadline.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL)
+	_, err := client.Greet(ctx, connect.NewRequest(&greet.GreetRequest{Name: "eliza"}))
+	require.Error(t, err)
+
+	require.Equal(t, 1, testutil.CollectAndCount(serverMetrics.deadlineBudget))
+	require.EqualValues(t, 1, testutil.ToFloat64(serverMetrics.noDeadline.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet")))
+}
@@ -1,9 +1,17 @@
 package connect_go_prometheus
 
 import (
+	"sort"
+	"sync"
+
+	"connectrpc.com/connect"
 	prom "github.com/prometheus/client_golang/prometheus"
 )
 
+// DefDeadlineBudgetBuckets are the default buckets of the deadline budget histogram. A value of 1
+// means the RPC took its entire deadline.
+var DefDeadlineBudgetBuckets = []float64{0.05, 0.1, 0.25, 0.5, 0.75, 0.9, 1}
+
 var (
 	DefaultClientMetrics = NewClientMetrics()
 	DefaultServerMetrics = NewServerMetrics()
@@ -15,183 +23,215 @@ func init() {
 	prom.MustRegister(DefaultClientMetrics)
 }
 
-// NewServerMetrics creates new Connect metrics for server-side handling.
+// NewServerMetrics creates new Connect metrics for server-side handling. It panics when the options produce
+// invalid metric or label names, see TryNewServerMetrics.
 func NewServerMetrics(opts ...MetricsOption) *Metrics {
-	config := evaluateMetricsOptions(&metricsOptions{
-		histogramBuckets:          prom.DefBuckets,
-		requestStartedName:        "connect_server_started_total",
-		requestHandledName:        "connect_server_handled_total",
-		requestHandledSecondsName: "connect_server_handled_seconds",
-		streamMsgSentName:         "connect_server_msg_sent_total",
-		streamMsgReceivedName:     "connect_server_msg_received_total",
-		bytesSentName:             "connect_server_bytes_sent_total",
-		bytesReceivedName:         "connect_server_bytes_received_total",
-		inflightRequestsName:      "connect_server_inflight_requests",
-	}, opts...)
+	config := evaluateMetricsOptions(serverMetricsOptions(), opts...)
+	if invalid, _ := validateMetricsOptions(config, newServerMetrics); len(invalid) > 0 {
+		panic(metricsOptionsError(invalid))
+	}
+	return newServerMetrics(config)
+}
+
+// TryNewServerMetrics is like NewServerMetrics, but returns an error when the options produce invalid metric
+// or label names, const labels clash with the labels of the metrics, or names violate the Prometheus naming
+// conventions checked by promlint.
+func TryNewServerMetrics(opts ...MetricsOption) (*Metrics, error) {
+	config := evaluateMetricsOptions(serverMetricsOptions(), opts...)
+	if invalid, violations := validateMetricsOptions(config, newServerMetrics); len(invalid)+len(violations) > 0 {
+		return nil, metricsOptionsError(append(invalid, violations...))
+	}
+	return newServerMetrics(config), nil
+}
 
+func newServerMetrics(config *metricsOptions) *Metrics {
 	m := &Metrics{
-		isClient: false,
-		requestStarted: prom.NewCounterVec(prom.CounterOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.requestStartedName,
-			Help:        "Total number of RPCs started handling server-side",
-		}, []string{"type", "service", "method"}),
-		requestHandled: prom.NewCounterVec(prom.CounterOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.requestHandledName,
-			Help:        "Total number of RPCs handled server-side",
-		}, []string{"type", "service", "method", "code"}),
-		streamMsgSent: prom.NewCounterVec(prom.CounterOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.streamMsgSentName,
-			Help:        "Total number of stream messages sent by server-side",
-		}, []string{"type", "service", "method"}),
-		streamMsgReceived: prom.NewCounterVec(prom.CounterOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.streamMsgReceivedName,
-			Help:        "Total number of stream messages received by server-side",
-		}, []string{"type", "service", "method"}),
+		isClient:           false,
+		typeValues:         config.typeValues,
+		codeValues:         config.codeValues,
+		secondsWithoutCode: config.secondsWithoutCode,
+		unaryMessages:      config.unaryMessages,
+		families:           make(map[*prom.Desc]MetricFamily),
 	}
 
+	m.requestStarted = m.newCounterVec(config, config.requestStartedName, "Total number of RPCs started handling server-side", config.callLabels())
+	m.requestHandled = m.newCounterVec(config, config.requestHandledName, "Total number of RPCs handled server-side", config.callLabels(config.codeLabel))
+	m.streamMsgSent = m.newCounterVec(config, config.streamMsgSentName, "Total number of stream messages sent by server-side", config.callLabels())
+	m.streamMsgReceived = m.newCounterVec(config, config.streamMsgReceivedName, "Total number of stream messages received by server-side", config.callLabels())
+	m.panics = m.newCounterVec(config, config.panicsName, "Total number of RPCs which panicked server-side", config.callLabels())
+	m.httpRequests = m.newCounterVec(config, config.httpRequestsName, "Total number of HTTP requests received server-side", config.procedureLabels("content_type", "status"))
+	m.httpRejected = m.newCounterVec(config, config.httpRejectedName, "Total number of HTTP requests rejected server-side before reaching the interceptor", config.procedureLabels("content_type", "status"))
+
 	if config.withHistogram {
-		m.requestHandledSeconds = prom.NewHistogramVec(prom.HistogramOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.requestHandledSecondsName,
-			Help:        "Histogram of RPCs handled server-side",
-			Buckets:     config.histogramBuckets,
-		}, []string{"type", "service", "method", "code"})
+		m.requestHandledSeconds = m.newHistogramVec(config, config.requestHandledSecondsName, "Histogram of RPCs handled server-side", config.histogramBuckets, config.handledSecondsLabels())
 	}
 
 	if config.withByteMetrics {
-		m.bytesSent = prom.NewCounterVec(prom.CounterOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.bytesSentName,
-			Help:        "Total number of bytes sent by server-side",
-		}, []string{"type", "service", "method"})
-		m.bytesReceived = prom.NewCounterVec(prom.CounterOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.bytesReceivedName,
-			Help:        "Total number of bytes received by server-side",
-		}, []string{"type", "service", "method"})
+		m.bytesSent = m.newCounterVec(config, config.bytesSentName, "Total number of bytes sent by server-side", config.callLabels())
+		m.bytesReceived = m.newCounterVec(config, config.bytesReceivedName, "Total number of bytes received by server-side", config.callLabels())
 	}
 
 	if config.withInflightMetrics {
-		m.inflightRequests = prom.NewGaugeVec(prom.GaugeOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.inflightRequestsName,
-			Help:        "Current number of inflight RPCs server-side",
-		}, []string{"type", "service", "method"})
+		m.inflightRequests = m.newGaugeVec(config, config.inflightRequestsName, "Current number of inflight RPCs server-side", config.callLabels())
+	}
+
+	if config.withCancelSourceMetrics {
+		m.canceled = m.newCounterVec(config, config.canceledName, "Total number of RPCs canceled or past their deadline server-side, by the source of the cancellation", config.callLabels(config.codeLabel, "cancel_source"))
+	}
+
+	if config.withDeadlineMetrics {
+		m.deadlineBudget = m.newHistogramVec(config, config.deadlineBudgetName, "Histogram of the ratio of time taken to the deadline of RPCs handled server-side", config.deadlineBudgetBuckets, config.callLabels())
+		m.noDeadline = m.newCounterVec(config, config.noDeadlineName, "Total number of RPCs received without a deadline server-side", config.callLabels())
+	}
+
+	if len(config.slos) > 0 {
+		m.slos = make(map[string]SLO, len(config.slos))
+		m.sloGood = m.newCounterVec(config, config.sloGoodName, "Total number of RPCs handled server-side meeting their SLO", config.procedureLabels())
+		m.sloTotal = m.newCounterVec(config, config.sloTotalName, "Total number of RPCs handled server-side with a SLO", config.procedureLabels())
+		for _, slo := range config.slos {
+			service, method := procedureToPackageAndMethod(slo.Procedure)
+			m.slos[service+"/"+method] = slo
+			m.sloGood.WithLabelValues(service, method)
+			m.sloTotal.WithLabelValues(service, method)
+		}
 	}
 
 	return m
 }
 
+// NewClientMetrics creates new Connect metrics for client-side calls. It panics when the options produce
+// invalid metric or label names, see TryNewClientMetrics.
 func NewClientMetrics(opts ...MetricsOption) *Metrics {
-	config := evaluateMetricsOptions(&metricsOptions{
-		histogramBuckets:          prom.DefBuckets,
-		requestStartedName:        "connect_client_started_total",
-		requestHandledName:        "connect_client_handled_total",
-		requestHandledSecondsName: "connect_client_handled_seconds",
-		streamMsgSentName:         "connect_client_msg_sent_total",
-		streamMsgReceivedName:     "connect_client_msg_received_total",
-		bytesSentName:             "connect_client_bytes_sent_total",
-		bytesReceivedName:         "connect_client_bytes_received_total",
-		inflightRequestsName:      "connect_client_inflight_requests",
-	}, opts...)
+	config := evaluateMetricsOptions(clientMetricsOptions(), opts...)
+	if invalid, _ := validateMetricsOptions(config, newClientMetrics); len(invalid) > 0 {
+		panic(metricsOptionsError(invalid))
+	}
+	return newClientMetrics(config)
+}
+
+// TryNewClientMetrics is like NewClientMetrics, but returns an error when the options are invalid or violate
+// the Prometheus naming conventions, see TryNewServerMetrics.
+func TryNewClientMetrics(opts ...MetricsOption) (*Metrics, error) {
+	config := evaluateMetricsOptions(clientMetricsOptions(), opts...)
+	if invalid, violations := validateMetricsOptions(config, newClientMetrics); len(invalid)+len(violations) > 0 {
+		return nil, metricsOptionsError(append(invalid, violations...))
+	}
+	return newClientMetrics(config), nil
+}
 
+func newClientMetrics(config *metricsOptions) *Metrics {
 	m := &Metrics{
-		isClient: true,
-		requestStarted: prom.NewCounterVec(prom.CounterOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.requestStartedName,
-			Help:        "Total number of RPCs started handling client-side",
-		}, []string{"type", "service", "method"}),
-		requestHandled: prom.NewCounterVec(prom.CounterOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.requestHandledName,
-			Help:        "Total number of RPCs handled client-side",
-		}, []string{"type", "service", "method", "code"}),
-		streamMsgSent: prom.NewCounterVec(prom.CounterOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.streamMsgSentName,
-			Help:        "Total number of stream messages sent by client-side",
-		}, []string{"type", "service", "method"}),
-		streamMsgReceived: prom.NewCounterVec(prom.CounterOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.streamMsgReceivedName,
-			Help:        "Total number of stream messages received by client-side",
-		}, []string{"type", "service", "method"}),
+		isClient:           true,
+		typeValues:         config.typeValues,
+		codeValues:         config.codeValues,
+		secondsWithoutCode: config.secondsWithoutCode,
+		unaryMessages:      config.unaryMessages,
+		families:           make(map[*prom.Desc]MetricFamily),
 	}
 
+	m.requestStarted = m.newCounterVec(config, config.requestStartedName, "Total number of RPCs started handling client-side", config.callLabels())
+	m.requestHandled = m.newCounterVec(config, config.requestHandledName, "Total number of RPCs handled client-side", config.callLabels(config.codeLabel))
+	m.streamMsgSent = m.newCounterVec(config, config.streamMsgSentName, "Total number of stream messages sent by client-side", config.callLabels())
+	m.streamMsgReceived = m.newCounterVec(config, config.streamMsgReceivedName, "Total number of stream messages received by client-side", config.callLabels())
+	m.retryBudget = m.newGaugeVec(config, config.retryBudgetName, "Fraction of the retry budget remaining client-side, as observed by the retry budget interceptor", config.procedureLabels())
+
 	if config.withHistogram {
-		m.requestHandledSeconds = prom.NewHistogramVec(prom.HistogramOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.requestHandledSecondsName,
-			Help:        "Histogram of RPCs handled client-side",
-			Buckets:     config.histogramBuckets,
-		}, []string{"type", "service", "method", "code"})
+		m.requestHandledSeconds = m.newHistogramVec(config, config.requestHandledSecondsName, "Histogram of RPCs handled client-side", config.histogramBuckets, config.handledSecondsLabels())
 	}
 
 	if config.withByteMetrics {
-		m.bytesSent = prom.NewCounterVec(prom.CounterOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.bytesSentName,
-			Help:        "Total number of bytes sent by client-side",
-		}, []string{"type", "service", "method"})
-		m.bytesReceived = prom.NewCounterVec(prom.CounterOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.bytesReceivedName,
-			Help:        "Total number of bytes received by client-side",
-		}, []string{"type", "service", "method"})
+		m.bytesSent = m.newCounterVec(config, config.bytesSentName, "Total number of bytes sent by client-side", config.callLabels())
+		m.bytesReceived = m.newCounterVec(config, config.bytesReceivedName, "Total number of bytes received by client-side", config.callLabels())
 	}
 
 	if config.withInflightMetrics {
-		m.inflightRequests = prom.NewGaugeVec(prom.GaugeOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.inflightRequestsName,
-			Help:        "Current number of inflight RPCs client-side",
-		}, []string{"type", "service", "method"})
+		m.inflightRequests = m.newGaugeVec(config, config.inflightRequestsName, "Current number of inflight RPCs client-side", config.callLabels())
+	}
+
+	if config.withTransportMetrics {
+		m.dnsSeconds = m.newHistogramVec(config, config.dnsSecondsName, "Histogram of DNS lookups for RPCs client-side", config.histogramBuckets, config.callLabels())
+		m.dialSeconds = m.newHistogramVec(config, config.dialSecondsName, "Histogram of establishing new connections for RPCs client-side", config.histogramBuckets, config.callLabels())
+		m.tlsHandshakeSeconds = m.newHistogramVec(config, config.tlsHandshakeSecondsName, "Histogram of TLS handshakes for RPCs client-side", config.histogramBuckets, config.callLabels())
+		m.firstByteSeconds = m.newHistogramVec(config, config.firstByteSecondsName, "Histogram of time from obtaining a connection to the first response byte for RPCs client-side", config.histogramBuckets, config.callLabels())
+		m.conns = m.newCounterVec(config, config.connsName, "Total number of connections obtained for RPCs client-side, by whether they were reused and idle", config.procedureLabels("peer", "reused", "was_idle"))
+		m.connIdleSeconds = m.newCounterVec(config, config.connIdleSecondsName, "Total time reused connections spent idle before being obtained for RPCs client-side", config.procedureLabels("peer"))
+	}
+
+	if config.withAttemptMetrics {
+		m.attempts = m.newCounterVec(config, config.attemptsName, "Total number of RPC attempts handled client-side", config.callLabels("attempt", config.codeLabel))
+		m.logicalCalls = m.newCounterVec(config, config.logicalCallsName, "Total number of logical RPCs completed client-side, by the number of attempts made and the code of the last one", config.callLabels("attempts", config.codeLabel))
 	}
 
 	return m
 }
 
-var _ prom.Collector = (*Metrics)(nil)
+// newCounterVec creates a counter of the family name, and records its description.
+func (m *Metrics) newCounterVec(config *metricsOptions, name, help string, labels []string) *prom.CounterVec {
+	v := prom.NewCounterVec(prom.CounterOpts{
+		Namespace:   config.namespace,
+		Subsystem:   config.subsystem,
+		ConstLabels: config.constLabels,
+		Name:        name,
+		Help:        help,
+	}, labels)
+	m.addFamily(v, config, "counter", name, help, labels)
+	return v
+}
+
+// newGaugeVec creates a gauge of the family name, and records its description.
+func (m *Metrics) newGaugeVec(config *metricsOptions, name, help string, labels []string) *prom.GaugeVec {
+	v := prom.NewGaugeVec(prom.GaugeOpts{
+		Namespace:   config.namespace,
+		Subsystem:   config.subsystem,
+		ConstLabels: config.constLabels,
+		Name:        name,
+		Help:        help,
+	}, labels)
+	m.addFamily(v, config, "gauge", name, help, labels)
+	return v
+}
+
+// newHistogramVec creates a histogram of the family name, and records its description.
+func (m *Metrics) newHistogramVec(config *metricsOptions, name, help string, buckets []float64, labels []string) *prom.HistogramVec {
+	v := prom.NewHistogramVec(prom.HistogramOpts{
+		Namespace:   config.namespace,
+		Subsystem:   config.subsystem,
+		ConstLabels: config.constLabels,
+		Name:        name,
+		Help:        help,
+		Buckets:     buckets,
+	}, labels)
+	m.addFamily(v, config, "histogram", name, help, labels)
+	return v
+}
+
+func (m *Metrics) addFamily(c prom.Collector, config *metricsOptions, typ, name, help string, labels []string) {
+	family := MetricFamily{
+		Name:   prom.BuildFQName(config.namespace, config.subsystem, name),
+		Type:   typ,
+		Help:   help,
+		Labels: append([]string{}, labels...),
+	}
+	for label := range config.constLabels {
+		family.ConstLabels = append(family.ConstLabels, label)
+	}
+	sort.Strings(family.ConstLabels)
+	for _, desc := range describe(c) {
+		m.families[desc] = family
+	}
+}
+
+var (
+	_ prom.Collector = (*Metrics)(nil)
+	_ Recorder       = (*Metrics)(nil)
+)
 
 type Metrics struct {
 	isClient              bool
+	typeValues            map[string]string
+	codeValues            map[string]string
+	secondsWithoutCode    bool
+	unaryMessages         bool
 	requestStarted        *prom.CounterVec
 	requestHandled        *prom.CounterVec
 	requestHandledSeconds *prom.HistogramVec
@@ -200,6 +240,44 @@ type Metrics struct {
 	bytesSent             *prom.CounterVec
 	bytesReceived         *prom.CounterVec
 	inflightRequests      *prom.GaugeVec
+	panics                *prom.CounterVec
+	canceled              *prom.CounterVec
+	deadlineBudget        *prom.HistogramVec
+	noDeadline            *prom.CounterVec
+	httpRequests          *prom.CounterVec
+	httpRejected          *prom.CounterVec
+	dnsSeconds            *prom.HistogramVec
+	dialSeconds           *prom.HistogramVec
+	tlsHandshakeSeconds   *prom.HistogramVec
+	firstByteSeconds      *prom.HistogramVec
+	conns                 *prom.CounterVec
+	connIdleSeconds       *prom.CounterVec
+	attempts              *prom.CounterVec
+	logicalCalls          *prom.CounterVec
+	retryBudget           *prom.GaugeVec
+	sloGood               *prom.CounterVec
+	sloTotal              *prom.CounterVec
+
+	// families describe the metric families created, by their descriptor, see Catalogue.
+	families map[*prom.Desc]MetricFamily
+
+	// slos are keyed by service/method.
+	slos map[string]SLO
+	// retryBudgets are read into the retry budget gauge when collected, see NewRetryBudgetInterceptor.
+	retryBudgetsMu sync.Mutex
+	retryBudgets   []*RetryBudgetInterceptor
+	// procedures are those initialized or intercepted, keyed by service/method, to label the HTTP requests of.
+	procedures sync.Map
+}
+
+// values returns the type and code label values reported for callType and code, see WithGRPCPrometheusNames.
+func (m *Metrics) values(callType, code string) (string, string) {
+	return labelValue(m.typeValues, callType), labelValue(m.codeValues, code)
+}
+
+// typeValue returns the type label value reported for callType.
+func (m *Metrics) typeValue(callType string) string {
+	return labelValue(m.typeValues, callType)
 }
 
 func (m *Metrics) Reset() {
@@ -219,6 +297,57 @@ func (m *Metrics) Reset() {
 	if m.inflightRequests != nil {
 		m.inflightRequests.Reset()
 	}
+	if m.panics != nil {
+		m.panics.Reset()
+	}
+	if m.canceled != nil {
+		m.canceled.Reset()
+	}
+	if m.deadlineBudget != nil {
+		m.deadlineBudget.Reset()
+	}
+	if m.noDeadline != nil {
+		m.noDeadline.Reset()
+	}
+	if m.httpRequests != nil {
+		m.httpRequests.Reset()
+	}
+	if m.httpRejected != nil {
+		m.httpRejected.Reset()
+	}
+	if m.dnsSeconds != nil {
+		m.dnsSeconds.Reset()
+	}
+	if m.dialSeconds != nil {
+		m.dialSeconds.Reset()
+	}
+	if m.tlsHandshakeSeconds != nil {
+		m.tlsHandshakeSeconds.Reset()
+	}
+	if m.firstByteSeconds != nil {
+		m.firstByteSeconds.Reset()
+	}
+	if m.conns != nil {
+		m.conns.Reset()
+	}
+	if m.connIdleSeconds != nil {
+		m.connIdleSeconds.Reset()
+	}
+	if m.attempts != nil {
+		m.attempts.Reset()
+	}
+	if m.logicalCalls != nil {
+		m.logicalCalls.Reset()
+	}
+	if m.retryBudget != nil {
+		m.retryBudget.Reset()
+	}
+	if m.sloGood != nil {
+		m.sloGood.Reset()
+	}
+	if m.sloTotal != nil {
+		m.sloTotal.Reset()
+	}
 }
 
 // Describe implements Describe as required by prom.Collector
@@ -239,6 +368,57 @@ func (m *Metrics) Describe(c chan<- *prom.Desc) {
 	if m.inflightRequests != nil {
 		m.inflightRequests.Describe(c)
 	}
+	if m.panics != nil {
+		m.panics.Describe(c)
+	}
+	if m.canceled != nil {
+		m.canceled.Describe(c)
+	}
+	if m.deadlineBudget != nil {
+		m.deadlineBudget.Describe(c)
+	}
+	if m.noDeadline != nil {
+		m.noDeadline.Describe(c)
+	}
+	if m.httpRequests != nil {
+		m.httpRequests.Describe(c)
+	}
+	if m.httpRejected != nil {
+		m.httpRejected.Describe(c)
+	}
+	if m.dnsSeconds != nil {
+		m.dnsSeconds.Describe(c)
+	}
+	if m.dialSeconds != nil {
+		m.dialSeconds.Describe(c)
+	}
+	if m.tlsHandshakeSeconds != nil {
+		m.tlsHandshakeSeconds.Describe(c)
+	}
+	if m.firstByteSeconds != nil {
+		m.firstByteSeconds.Describe(c)
+	}
+	if m.conns != nil {
+		m.conns.Describe(c)
+	}
+	if m.connIdleSeconds != nil {
+		m.connIdleSeconds.Describe(c)
+	}
+	if m.attempts != nil {
+		m.attempts.Describe(c)
+	}
+	if m.logicalCalls != nil {
+		m.logicalCalls.Describe(c)
+	}
+	if m.retryBudget != nil {
+		m.retryBudget.Describe(c)
+	}
+	if m.sloGood != nil {
+		m.sloGood.Describe(c)
+	}
+	if m.sloTotal != nil {
+		m.sloTotal.Describe(c)
+	}
 }
 
 // Collect implements collect as required by prom.Collector
@@ -259,9 +439,77 @@ func (m *Metrics) Collect(c chan<- prom.Metric) {
 	if m.inflightRequests != nil {
 		m.inflightRequests.Collect(c)
 	}
+	if m.panics != nil {
+		m.panics.Collect(c)
+	}
+	if m.canceled != nil {
+		m.canceled.Collect(c)
+	}
+	if m.deadlineBudget != nil {
+		m.deadlineBudget.Collect(c)
+	}
+	if m.noDeadline != nil {
+		m.noDeadline.Collect(c)
+	}
+	if m.httpRequests != nil {
+		m.httpRequests.Collect(c)
+	}
+	if m.httpRejected != nil {
+		m.httpRejected.Collect(c)
+	}
+	if m.dnsSeconds != nil {
+		m.dnsSeconds.Collect(c)
+	}
+	if m.dialSeconds != nil {
+		m.dialSeconds.Collect(c)
+	}
+	if m.tlsHandshakeSeconds != nil {
+		m.tlsHandshakeSeconds.Collect(c)
+	}
+	if m.firstByteSeconds != nil {
+		m.firstByteSeconds.Collect(c)
+	}
+	if m.conns != nil {
+		m.conns.Collect(c)
+	}
+	if m.connIdleSeconds != nil {
+		m.connIdleSeconds.Collect(c)
+	}
+	if m.attempts != nil {
+		m.attempts.Collect(c)
+	}
+	if m.logicalCalls != nil {
+		m.logicalCalls.Collect(c)
+	}
+	if m.retryBudget != nil {
+		m.collectRetryBudgets()
+		m.retryBudget.Collect(c)
+	}
+	if m.sloGood != nil {
+		m.sloGood.Collect(c)
+	}
+	if m.sloTotal != nil {
+		m.sloTotal.Collect(c)
+	}
+}
+
+// Initialize creates the started and handled series for the given call with a zero value, for every
+// code returned by Codes. This allows queries such as rate() to work before the first RPC completes.
+func (m *Metrics) Initialize(callType, service, method string) {
+	callType = m.typeValue(callType)
+	m.procedures.Store(service+"/"+method, struct{}{})
+	m.requestStarted.WithLabelValues(callType, service, method)
+	for _, code := range codes {
+		code = labelValue(m.codeValues, code)
+		m.requestHandled.WithLabelValues(callType, service, method, code)
+		if m.requestHandledSeconds != nil {
+			m.requestHandledSeconds.WithLabelValues(m.handledSecondsValues(callType, service, method, code)...)
+		}
+	}
 }
 
 func (m *Metrics) ReportStarted(callType, service, method string) {
+	callType = m.typeValue(callType)
 	m.requestStarted.WithLabelValues(callType, service, method).Inc()
 	if m.inflightRequests != nil {
 		m.inflightRequests.WithLabelValues(callType, service, method).Inc()
@@ -269,6 +517,7 @@ func (m *Metrics) ReportStarted(callType, service, method string) {
 }
 
 func (m *Metrics) ReportHandled(callType, service, method, code string) {
+	callType, code = m.values(callType, code)
 	m.requestHandled.WithLabelValues(callType, service, method, code).Inc()
 	if m.inflightRequests != nil {
 		m.inflightRequests.WithLabelValues(callType, service, method).Dec()
@@ -277,11 +526,150 @@ func (m *Metrics) ReportHandled(callType, service, method, code string) {
 
 func (m *Metrics) ReportHandledSeconds(callType, service, method, code string, val float64) {
 	if m.requestHandledSeconds != nil {
-		m.requestHandledSeconds.WithLabelValues(callType, service, method, code).Observe(val)
+		callType, code := m.values(callType, code)
+		m.requestHandledSeconds.WithLabelValues(m.handledSecondsValues(callType, service, method, code)...).Observe(val)
+	}
+	if len(m.slos) == 0 {
+		// Avoid building the key for every RPC when no SLOs are configured.
+		return
+	}
+	if slo, ok := m.slos[service+"/"+method]; ok {
+		m.sloTotal.WithLabelValues(service, method).Inc()
+		if slo.good(code, val) {
+			m.sloGood.WithLabelValues(service, method).Inc()
+		}
+	}
+}
+
+// handledSecondsValues returns the label values of the handled seconds histogram, see handledSecondsLabels.
+func (m *Metrics) handledSecondsValues(callType, service, method, code string) []string {
+	if m.secondsWithoutCode {
+		return []string{callType, service, method}
+	}
+	return []string{callType, service, method, code}
+}
+
+func (m *Metrics) ReportMsgSent(callType, service, method string) {
+	callType = m.typeValue(callType)
+	m.streamMsgSent.WithLabelValues(callType, service, method).Inc()
+}
+
+func (m *Metrics) ReportMsgReceived(callType, service, method string) {
+	callType = m.typeValue(callType)
+	m.streamMsgReceived.WithLabelValues(callType, service, method).Inc()
+}
+
+func (m *Metrics) reportUnaryMsgSent(callType, service, method string) {
+	if m.unaryMessages {
+		m.ReportMsgSent(callType, service, method)
+	}
+}
+
+func (m *Metrics) reportUnaryMsgReceived(callType, service, method string) {
+	if m.unaryMessages {
+		m.ReportMsgReceived(callType, service, method)
+	}
+}
+
+func (m *Metrics) ReportBytesSent(callType, service, method string, bytes int) {
+	callType = m.typeValue(callType)
+	if m.bytesSent != nil {
+		m.bytesSent.WithLabelValues(callType, service, method).Add(float64(bytes))
+	}
+}
+
+func (m *Metrics) ReportBytesReceived(callType, service, method string, bytes int) {
+	callType = m.typeValue(callType)
+	if m.bytesReceived != nil {
+		m.bytesReceived.WithLabelValues(callType, service, method).Add(float64(bytes))
+	}
+}
+
+func (m *Metrics) reportsBytes() bool {
+	return m.bytesSent != nil || m.bytesReceived != nil
+}
+
+// ReportPanicked records a RPC which panicked. Panics are only tracked server-side.
+func (m *Metrics) ReportPanicked(callType, service, method string) {
+	callType = m.typeValue(callType)
+	if m.panics != nil {
+		m.panics.WithLabelValues(callType, service, method).Inc()
+	}
+}
+
+// ReportCanceled records the source of cancellation for a RPC which completed with a canceled
+// or deadline_exceeded code. It is a no-op unless cancel source metrics are enabled.
+func (m *Metrics) ReportCanceled(callType, service, method, code, source string) {
+	callType, code = m.values(callType, code)
+	if m.canceled != nil {
+		m.canceled.WithLabelValues(callType, service, method, code, source).Inc()
+	}
+}
+
+// ReportDeadlineBudget records the fraction of the request deadline a RPC took to complete.
+// It is a no-op unless deadline metrics are enabled.
+func (m *Metrics) ReportDeadlineBudget(callType, service, method string, ratio float64) {
+	callType = m.typeValue(callType)
+	if m.deadlineBudget != nil {
+		m.deadlineBudget.WithLabelValues(callType, service, method).Observe(ratio)
+	}
+}
+
+// ReportNoDeadline records a RPC received without a deadline. It is a no-op unless deadline metrics are enabled.
+func (m *Metrics) ReportNoDeadline(callType, service, method string) {
+	callType = m.typeValue(callType)
+	if m.noDeadline != nil {
+		m.noDeadline.WithLabelValues(callType, service, method).Inc()
+	}
+}
+
+// ReportHTTPRequest records a HTTP request served by a handler wrapped with WrapHandler. Requests which
+// were not intercepted are additionally counted as rejected.
+func (m *Metrics) ReportHTTPRequest(service, method, contentType, status string, intercepted bool) {
+	if m.httpRequests != nil {
+		m.httpRequests.WithLabelValues(service, method, contentType, status).Inc()
+	}
+	if m.httpRejected != nil && !intercepted {
+		m.httpRejected.WithLabelValues(service, method, contentType, status).Inc()
+	}
+}
+
+// ReportAttempt records the outcome of an attempt of a logical call, see WithAttempt.
+// It is a no-op unless attempt metrics are enabled.
+func (m *Metrics) ReportAttempt(callType, service, method, attempt, code string) {
+	callType, code = m.values(callType, code)
+	if m.attempts != nil {
+		m.attempts.WithLabelValues(callType, service, method, attempt, code).Inc()
+	}
+}
+
+// ReportLogicalCall records the final outcome of a logical call, see WithLogicalCall.
+// It is a no-op unless attempt metrics are enabled.
+func (m *Metrics) ReportLogicalCall(callType, service, method, attempts, code string) {
+	callType, code = m.values(callType, code)
+	if m.logicalCalls != nil {
+		m.logicalCalls.WithLabelValues(callType, service, method, attempts, code).Inc()
+	}
+}
+
+// ReportRetryBudget records the fraction of the retry budget remaining for a procedure.
+func (m *Metrics) ReportRetryBudget(service, method string, remaining float64) {
+	if m.retryBudget != nil {
+		m.retryBudget.WithLabelValues(service, method).Set(remaining)
 	}
 }
 
 type metricsOptions struct {
+	isClient bool
+
+	// typeValues and codeValues map the values reported in the type and code labels, when set.
+	typeValues map[string]string
+	codeValues map[string]string
+	// secondsWithoutCode drops the code label of the handled seconds histogram.
+	secondsWithoutCode bool
+	// unaryMessages counts the messages of unary calls in the stream message metrics.
+	unaryMessages bool
+
 	withHistogram    bool
 	histogramBuckets []float64
 
@@ -296,15 +684,123 @@ type metricsOptions struct {
 	bytesSentName             string
 	bytesReceivedName         string
 	inflightRequestsName      string
+	panicsName                string
+	canceledName              string
+	deadlineBudgetName        string
+	noDeadlineName            string
+	httpRequestsName          string
+	httpRejectedName          string
+	dnsSecondsName            string
+	dialSecondsName           string
+	tlsHandshakeSecondsName   string
+	firstByteSecondsName      string
+	connsName                 string
+	connIdleSecondsName       string
+	attemptsName              string
+	logicalCallsName          string
+	retryBudgetName           string
+	sloGoodName               string
+	sloTotalName              string
 
 	constLabels prom.Labels
 
 	withByteMetrics     bool
 	withInflightMetrics bool
+
+	withCancelSourceMetrics bool
+
+	withDeadlineMetrics   bool
+	deadlineBudgetBuckets []float64
+
+	withTransportMetrics bool
+
+	withAttemptMetrics bool
+
+	slos []SLO
+
+	typeLabel    string
+	serviceLabel string
+	methodLabel  string
+	codeLabel    string
+}
+
+// callLabels returns the labels of the metrics of a call, followed by extra labels.
+func (o *metricsOptions) callLabels(extra ...string) []string {
+	return append([]string{o.typeLabel, o.serviceLabel, o.methodLabel}, extra...)
+}
+
+// handledSecondsLabels returns the labels of the handled seconds histogram.
+func (o *metricsOptions) handledSecondsLabels() []string {
+	if o.secondsWithoutCode {
+		return o.callLabels()
+	}
+	return o.callLabels(o.codeLabel)
+}
+
+// procedureLabels returns the labels of the metrics of a procedure, followed by extra labels.
+func (o *metricsOptions) procedureLabels(extra ...string) []string {
+	return append([]string{o.serviceLabel, o.methodLabel}, extra...)
 }
 
 type MetricsOption func(opts *metricsOptions)
 
+// serverMetricsOptions returns the default options of server-side metrics.
+func serverMetricsOptions() *metricsOptions {
+	return &metricsOptions{
+		histogramBuckets:          prom.DefBuckets,
+		typeLabel:                 "type",
+		serviceLabel:              "service",
+		methodLabel:               "method",
+		codeLabel:                 "code",
+		requestStartedName:        "connect_server_started_total",
+		requestHandledName:        "connect_server_handled_total",
+		requestHandledSecondsName: "connect_server_handled_seconds",
+		streamMsgSentName:         "connect_server_msg_sent_total",
+		streamMsgReceivedName:     "connect_server_msg_received_total",
+		bytesSentName:             "connect_server_bytes_sent_total",
+		bytesReceivedName:         "connect_server_bytes_received_total",
+		inflightRequestsName:      "connect_server_inflight_requests",
+		panicsName:                "connect_server_panics_total",
+		canceledName:              "connect_server_canceled_total",
+		deadlineBudgetName:        "connect_server_deadline_budget_ratio",
+		noDeadlineName:            "connect_server_no_deadline_total",
+		deadlineBudgetBuckets:     DefDeadlineBudgetBuckets,
+		httpRequestsName:          "connect_server_http_requests_total",
+		httpRejectedName:          "connect_server_http_rejected_total",
+		sloGoodName:               "connect_slo_good_total",
+		sloTotalName:              "connect_slo_total",
+	}
+}
+
+// clientMetricsOptions returns the default options of client-side metrics.
+func clientMetricsOptions() *metricsOptions {
+	return &metricsOptions{
+		isClient:                  true,
+		histogramBuckets:          prom.DefBuckets,
+		typeLabel:                 "type",
+		serviceLabel:              "service",
+		methodLabel:               "method",
+		codeLabel:                 "code",
+		requestStartedName:        "connect_client_started_total",
+		requestHandledName:        "connect_client_handled_total",
+		requestHandledSecondsName: "connect_client_handled_seconds",
+		streamMsgSentName:         "connect_client_msg_sent_total",
+		streamMsgReceivedName:     "connect_client_msg_received_total",
+		bytesSentName:             "connect_client_bytes_sent_total",
+		bytesReceivedName:         "connect_client_bytes_received_total",
+		inflightRequestsName:      "connect_client_inflight_requests",
+		dnsSecondsName:            "connect_client_dns_seconds",
+		dialSecondsName:           "connect_client_dial_seconds",
+		tlsHandshakeSecondsName:   "connect_client_tls_handshake_seconds",
+		firstByteSecondsName:      "connect_client_first_byte_seconds",
+		connsName:                 "connect_client_conns_total",
+		connIdleSecondsName:       "connect_client_conn_idle_seconds_total",
+		attemptsName:              "connect_client_attempts_total",
+		logicalCallsName:          "connect_client_logical_calls_total",
+		retryBudgetName:           "connect_client_retry_budget_remaining_ratio",
+	}
+}
+
 func WithHistogram(enabled bool) MetricsOption {
 	return func(opts *metricsOptions) {
 		opts.withHistogram = enabled
@@ -347,6 +843,321 @@ func WithInflightMetrics(enabled bool) MetricsOption {
 	}
 }
 
+// WithCancelSourceMetrics enables a server-side counter of canceled and deadline_exceeded RPCs,
+// with a cancel_source label distinguishing a client disconnect, an expired request deadline and
+// a cancellation originating in the handler itself. It has no effect on client metrics.
+func WithCancelSourceMetrics(enabled bool) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.withCancelSourceMetrics = enabled
+	}
+}
+
+// WithDeadlineMetrics enables a server-side histogram of the ratio of time taken to the deadline of
+// each RPC, and a counter of RPCs received without a deadline. It has no effect on client metrics.
+func WithDeadlineMetrics(enabled bool) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.withDeadlineMetrics = enabled
+	}
+}
+
+// WithDeadlineBudgetBuckets overrides DefDeadlineBudgetBuckets for the deadline budget histogram.
+func WithDeadlineBudgetBuckets(buckets []float64) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.deadlineBudgetBuckets = buckets
+	}
+}
+
+// WithTransportMetrics enables client-side histograms of DNS lookups, connection establishment,
+// TLS handshakes and time from obtaining a connection to the first response byte, and counters of connection reuse, recorded by
+// a Transport. It has no effect on server metrics.
+func WithTransportMetrics(enabled bool) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.withTransportMetrics = enabled
+	}
+}
+
+// WithAttemptMetrics enables a client-side counter of RPC attempts with an attempt label, as marked
+// by WithAttempt, while the started and handled metrics count every attempt. It also enables a counter
+// of logical calls by the code of their last attempt, as reported by WithLogicalCall, exposing their
+// final outcome. It has no effect on server metrics.
+func WithAttemptMetrics(enabled bool) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.withAttemptMetrics = enabled
+	}
+}
+
+// WithSLOs enables server-side counters of the RPCs handled for each procedure with a SLO, and of those
+// meeting it, classified as they complete. See SLORules for the alerting rules on their burn rate. SLOs with
+// an availability outside (0, 1) are invalid, see TryNewServerMetrics. It has no effect on client metrics.
+func WithSLOs(slos ...SLO) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.slos = append(opts.slos, slos...)
+	}
+}
+
+// WithStartedName overrides the name of the counter of RPCs started, before the namespace and subsystem are prepended.
+func WithStartedName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.requestStartedName = name
+	}
+}
+
+// WithHandledName overrides the name of the counter of RPCs handled, before the namespace and subsystem are prepended.
+func WithHandledName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.requestHandledName = name
+	}
+}
+
+// WithHandledSecondsName overrides the name of the histogram of RPCs handled, before the namespace and subsystem are prepended.
+func WithHandledSecondsName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.requestHandledSecondsName = name
+	}
+}
+
+// WithMsgSentName overrides the name of the counter of stream messages sent, before the namespace and subsystem are prepended.
+func WithMsgSentName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.streamMsgSentName = name
+	}
+}
+
+// WithMsgReceivedName overrides the name of the counter of stream messages received, before the namespace and subsystem are prepended.
+func WithMsgReceivedName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.streamMsgReceivedName = name
+	}
+}
+
+// WithBytesSentName overrides the name of the counter of bytes sent, before the namespace and subsystem are prepended.
+func WithBytesSentName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.bytesSentName = name
+	}
+}
+
+// WithBytesReceivedName overrides the name of the counter of bytes received, before the namespace and subsystem are prepended.
+func WithBytesReceivedName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.bytesReceivedName = name
+	}
+}
+
+// WithInflightRequestsName overrides the name of the gauge of inflight RPCs, before the namespace and subsystem are prepended.
+func WithInflightRequestsName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.inflightRequestsName = name
+	}
+}
+
+// WithPanicsName overrides the name of the server-side counter of RPCs which panicked, before the namespace and subsystem are prepended.
+func WithPanicsName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.panicsName = name
+	}
+}
+
+// WithCanceledName overrides the name of the server-side counter of canceled RPCs, before the namespace and subsystem are prepended.
+func WithCanceledName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.canceledName = name
+	}
+}
+
+// WithDeadlineBudgetName overrides the name of the server-side histogram of the deadline budget, before the namespace and subsystem are prepended.
+func WithDeadlineBudgetName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.deadlineBudgetName = name
+	}
+}
+
+// WithNoDeadlineName overrides the name of the server-side counter of RPCs without a deadline, before the namespace and subsystem are prepended.
+func WithNoDeadlineName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.noDeadlineName = name
+	}
+}
+
+// WithHTTPRequestsName overrides the name of the server-side counter of HTTP requests, before the namespace and subsystem are prepended.
+func WithHTTPRequestsName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.httpRequestsName = name
+	}
+}
+
+// WithHTTPRejectedName overrides the name of the server-side counter of rejected HTTP requests, before the namespace and subsystem are prepended.
+func WithHTTPRejectedName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.httpRejectedName = name
+	}
+}
+
+// WithDNSSecondsName overrides the name of the client-side histogram of DNS lookups, before the namespace and subsystem are prepended.
+func WithDNSSecondsName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.dnsSecondsName = name
+	}
+}
+
+// WithDialSecondsName overrides the name of the client-side histogram of connection establishment, before the namespace and subsystem are prepended.
+func WithDialSecondsName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.dialSecondsName = name
+	}
+}
+
+// WithTLSHandshakeSecondsName overrides the name of the client-side histogram of TLS handshakes, before the namespace and subsystem are prepended.
+func WithTLSHandshakeSecondsName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.tlsHandshakeSecondsName = name
+	}
+}
+
+// WithFirstByteSecondsName overrides the name of the client-side histogram of time to first response byte, before the namespace and subsystem are prepended.
+func WithFirstByteSecondsName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.firstByteSecondsName = name
+	}
+}
+
+// WithConnsName overrides the name of the client-side counter of connections obtained, before the namespace and subsystem are prepended.
+func WithConnsName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.connsName = name
+	}
+}
+
+// WithConnIdleSecondsName overrides the name of the client-side counter of time connections spent idle, before the namespace and subsystem are prepended.
+func WithConnIdleSecondsName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.connIdleSecondsName = name
+	}
+}
+
+// WithAttemptsName overrides the name of the client-side counter of RPC attempts, before the namespace and subsystem are prepended.
+func WithAttemptsName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.attemptsName = name
+	}
+}
+
+// WithLogicalCallsName overrides the name of the client-side counter of logical calls, before the namespace and subsystem are prepended.
+func WithLogicalCallsName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.logicalCallsName = name
+	}
+}
+
+// WithRetryBudgetName overrides the name of the client-side gauge of the retry budget remaining, before the namespace and subsystem are prepended.
+func WithRetryBudgetName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.retryBudgetName = name
+	}
+}
+
+// WithSLOGoodName overrides the name of the server-side counter of RPCs meeting their SLO, before the namespace and subsystem are prepended.
+func WithSLOGoodName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.sloGoodName = name
+	}
+}
+
+// WithSLOTotalName overrides the name of the server-side counter of RPCs with a SLO, before the namespace and subsystem are prepended.
+func WithSLOTotalName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.sloTotalName = name
+	}
+}
+
+// LabelNames are the names of the labels identifying a call, see WithLabelNames.
+type LabelNames struct {
+	Type    string
+	Service string
+	Method  string
+	Code    string
+}
+
+// WithLabelNames overrides the names of the type, service, method and code labels. Empty names keep their default.
+func WithLabelNames(names LabelNames) MetricsOption {
+	return func(opts *metricsOptions) {
+		if names.Type != "" {
+			opts.typeLabel = names.Type
+		}
+		if names.Service != "" {
+			opts.serviceLabel = names.Service
+		}
+		if names.Method != "" {
+			opts.methodLabel = names.Method
+		}
+		if names.Code != "" {
+			opts.codeLabel = names.Code
+		}
+	}
+}
+
+// WithGRPCPrometheusNames names the started, handled and stream message counters and the handled histogram,
+// and the type, service, method and code labels, as go-grpc-prometheus does, such as grpc_server_handled_total
+// with grpc_type, grpc_service, grpc_method and grpc_code labels. The type and code label values of every metric
+// are also spelled as go-grpc-prometheus does, such as bidi_stream and OK rather than bidi and ok. As with
+// go-grpc-prometheus, the handled histogram has no code label, and the message counters also count the request
+// and response of unary calls, so that existing dashboards and alerts keep working. Other metrics keep their names.
+func WithGRPCPrometheusNames() MetricsOption {
+	return func(opts *metricsOptions) {
+		side := "server"
+		if opts.isClient {
+			side = "client"
+		}
+		opts.requestStartedName = "grpc_" + side + "_started_total"
+		opts.requestHandledName = "grpc_" + side + "_handled_total"
+		opts.requestHandledSecondsName = "grpc_" + side + "_handling_seconds"
+		opts.streamMsgSentName = "grpc_" + side + "_msg_sent_total"
+		opts.streamMsgReceivedName = "grpc_" + side + "_msg_received_total"
+		opts.typeLabel = "grpc_type"
+		opts.serviceLabel = "grpc_service"
+		opts.methodLabel = "grpc_method"
+		opts.codeLabel = "grpc_code"
+		opts.typeValues = grpcTypeValues
+		opts.codeValues = grpcCodeValues
+		opts.secondsWithoutCode = true
+		opts.unaryMessages = true
+	}
+}
+
+// grpcTypeValues are the go-grpc-prometheus spellings of the call types which differ.
+var grpcTypeValues = map[string]string{
+	"bidi": "bidi_stream",
+}
+
+// grpcCodeValues are the go-grpc-prometheus spellings of the codes, those of grpc-go's codes.Code.
+var grpcCodeValues = map[string]string{
+	CodeOk:                                  "OK",
+	connect.CodeCanceled.String():           "Canceled",
+	connect.CodeUnknown.String():            "Unknown",
+	connect.CodeInvalidArgument.String():    "InvalidArgument",
+	connect.CodeDeadlineExceeded.String():   "DeadlineExceeded",
+	connect.CodeNotFound.String():           "NotFound",
+	connect.CodeAlreadyExists.String():      "AlreadyExists",
+	connect.CodePermissionDenied.String():   "PermissionDenied",
+	connect.CodeResourceExhausted.String():  "ResourceExhausted",
+	connect.CodeFailedPrecondition.String(): "FailedPrecondition",
+	connect.CodeAborted.String():            "Aborted",
+	connect.CodeOutOfRange.String():         "OutOfRange",
+	connect.CodeUnimplemented.String():      "Unimplemented",
+	connect.CodeInternal.String():           "Internal",
+	connect.CodeUnavailable.String():        "Unavailable",
+	connect.CodeDataLoss.String():           "DataLoss",
+	connect.CodeUnauthenticated.String():    "Unauthenticated",
+}
+
+// labelValue returns the value mapped from value by values, or value itself when it isn't mapped.
+func labelValue(values map[string]string, value string) string {
+	if mapped, ok := values[value]; ok {
+		return mapped
+	}
+	return value
+}
+
 func evaluateMetricsOptions(defaults *metricsOptions, opts ...MetricsOption) *metricsOptions {
 	for _, opt := range opts {
 		opt(defaults)
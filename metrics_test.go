@@ -130,3 +130,65 @@ func TestClientMetrics(t *testing.T) {
 	`))
 	require.NoError(t, err)
 }
+
+func TestMetrics_Initialize(t *testing.T) {
+	sm := NewServerMetrics(WithHistogram(true))
+	sm.Initialize("unary", greetconnect.GreetServiceName, "Greet")
+
+	require.Equal(t, 1, testutil.CollectAndCount(sm.requestStarted))
+	require.Equal(t, len(Codes()), testutil.CollectAndCount(sm.requestHandled))
+	require.Equal(t, len(Codes()), testutil.CollectAndCount(sm.requestHandledSeconds))
+	require.EqualValues(t, 0, testutil.ToFloat64(sm.requestHandled.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet", CodeOk)))
+}
+
+func TestMetrics_GRPCPrometheusNames(t *testing.T) {
+	sm := NewServerMetrics(WithGRPCPrometheusNames(), WithHistogram(true))
+	sm.ReportHandled("unary", greetconnect.GreetServiceName, "Greet", CodeOk)
+	sm.ReportHandled("bidi", greetconnect.GreetServiceName, "Greet", connect.CodeUnavailable.String())
+	sm.ReportHandled("server_stream", greetconnect.GreetServiceName, "Greet", connect.CodeDeadlineExceeded.String())
+	err := testutil.CollectAndCompare(sm.requestHandled, strings.NewReader(`
+			# HELP grpc_server_handled_total Total number of RPCs handled server-side
+			# TYPE grpc_server_handled_total counter
+			grpc_server_handled_total{grpc_code="DeadlineExceeded",grpc_method="Greet",grpc_service="greet.v1.GreetService",grpc_type="server_stream"} 1
+			grpc_server_handled_total{grpc_code="OK",grpc_method="Greet",grpc_service="greet.v1.GreetService",grpc_type="unary"} 1
+			grpc_server_handled_total{grpc_code="Unavailable",grpc_method="Greet",grpc_service="greet.v1.GreetService",grpc_type="bidi_stream"} 1
+		`))
+	require.NoError(t, err)
+
+	sm.Initialize("unary", greetconnect.GreetServiceName, "Greet")
+	require.EqualValues(t, 0, testutil.ToFloat64(sm.requestHandled.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet", "Unauthenticated")))
+	require.Equal(t, len(Codes())+2, testutil.CollectAndCount(sm.requestHandled), "initialized with the go-grpc-prometheus codes")
+	require.Equal(t, 1, testutil.CollectAndCount(sm.requestHandledSeconds), "the handling histogram has no code label")
+
+	sm.ReportHandledSeconds("unary", greetconnect.GreetServiceName, "Greet", connect.CodeUnavailable.String(), 0.1)
+	require.Equal(t, 1, testutil.CollectAndCount(sm.requestHandledSeconds))
+	for _, family := range Catalogue(sm) {
+		if family.Name == "grpc_server_handling_seconds" {
+			require.Equal(t, []string{"grpc_type", "grpc_service", "grpc_method"}, family.Labels)
+		}
+	}
+
+	var names []string
+	for _, family := range Catalogue(sm) {
+		names = append(names, family.Name)
+	}
+	require.Contains(t, names, "grpc_server_started_total")
+	require.Contains(t, names, "grpc_server_handling_seconds")
+	require.Contains(t, names, "grpc_server_msg_sent_total")
+	require.Contains(t, names, "connect_server_panics_total", "metrics without a go-grpc-prometheus equivalent keep their name")
+
+	cm := NewClientMetrics(WithGRPCPrometheusNames())
+	require.Equal(t, []string{"grpc_type", "grpc_service", "grpc_method", "grpc_code"}, Catalogue(cm)[1].Labels)
+	require.Equal(t, "grpc_client_handled_total", Catalogue(cm)[1].Name)
+}
+
+func TestMetrics_Names(t *testing.T) {
+	sm := NewServerMetrics(
+		WithNamespace("acme"),
+		WithHandledName("rpcs_total"),
+		WithLabelNames(LabelNames{Service: "rpc_service", Code: "status_code"}),
+	)
+	family := Catalogue(sm)[1]
+	require.Equal(t, "acme_rpcs_total", family.Name)
+	require.Equal(t, []string{"type", "rpc_service", "method", "status_code"}, family.Labels)
+}
@@ -0,0 +1,119 @@
+package connect_go_prometheus
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	otelInstrumentationName = "github.com/easyCZ/connect-go-prometheus"
+
+	otelRPCSystem       = attribute.Key("rpc.system")
+	otelRPCService      = attribute.Key("rpc.service")
+	otelRPCMethod       = attribute.Key("rpc.method")
+	otelRPCConnectError = attribute.Key("rpc.connect_rpc.error_code")
+)
+
+var otelRPCSystemConnect = otelRPCSystem.String("connect_rpc")
+
+// NewOTelServerMetrics creates OpenTelemetry metrics for server-side handling, following the
+// OpenTelemetry semantic conventions for RPC metrics. Use it with WithServerRecorder.
+func NewOTelServerMetrics(provider metric.MeterProvider) (*OTelMetrics, error) {
+	return newOTelMetrics(provider, "server", "inbound")
+}
+
+// NewOTelClientMetrics creates OpenTelemetry metrics for client-side calls, following the
+// OpenTelemetry semantic conventions for RPC metrics. Use it with WithClientRecorder.
+func NewOTelClientMetrics(provider metric.MeterProvider) (*OTelMetrics, error) {
+	return newOTelMetrics(provider, "client", "outbound")
+}
+
+func newOTelMetrics(provider metric.MeterProvider, side, direction string) (*OTelMetrics, error) {
+	meter := provider.Meter(otelInstrumentationName)
+	m := &OTelMetrics{isClient: side == "client"}
+
+	var err error
+	if m.duration, err = meter.Float64Histogram("rpc."+side+".duration",
+		metric.WithUnit("ms"),
+		metric.WithDescription("Measures the duration of "+direction+" RPC."),
+	); err != nil {
+		return nil, err
+	}
+	if m.requestSize, err = meter.Int64Histogram("rpc."+side+".request.size",
+		metric.WithUnit("By"),
+		metric.WithDescription("Measures the size of RPC request messages (uncompressed)."),
+	); err != nil {
+		return nil, err
+	}
+	if m.responseSize, err = meter.Int64Histogram("rpc."+side+".response.size",
+		metric.WithUnit("By"),
+		metric.WithDescription("Measures the size of RPC response messages (uncompressed)."),
+	); err != nil {
+		return nil, err
+	}
+	if m.activeRequests, err = meter.Int64UpDownCounter("rpc."+side+".active_requests",
+		metric.WithUnit("{request}"),
+		metric.WithDescription("Measures the number of concurrent "+direction+" RPCs that are currently in-flight."),
+	); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var _ Recorder = (*OTelMetrics)(nil)
+
+// OTelMetrics is a Recorder reporting to an OpenTelemetry metric.MeterProvider. Message counts are
+// not reported separately, as they are the counts of the request and response size histograms.
+type OTelMetrics struct {
+	isClient       bool
+	duration       metric.Float64Histogram
+	requestSize    metric.Int64Histogram
+	responseSize   metric.Int64Histogram
+	activeRequests metric.Int64UpDownCounter
+}
+
+func (m *OTelMetrics) ReportStarted(callType, service, method string) {
+	m.activeRequests.Add(context.Background(), 1, metric.WithAttributes(otelAttributes(service, method)...))
+}
+
+func (m *OTelMetrics) ReportHandled(callType, service, method, code string) {
+	m.activeRequests.Add(context.Background(), -1, metric.WithAttributes(otelAttributes(service, method)...))
+}
+
+func (m *OTelMetrics) ReportHandledSeconds(callType, service, method, code string, val float64) {
+	attrs := otelAttributes(service, method)
+	if code != CodeOk {
+		attrs = append(attrs, otelRPCConnectError.String(code))
+	}
+	m.duration.Record(context.Background(), val*1000, metric.WithAttributes(attrs...))
+}
+
+func (m *OTelMetrics) ReportMsgSent(callType, service, method string) {}
+
+func (m *OTelMetrics) ReportMsgReceived(callType, service, method string) {}
+
+func (m *OTelMetrics) ReportBytesSent(callType, service, method string, bytes int) {
+	if m.isClient {
+		m.requestSize.Record(context.Background(), int64(bytes), metric.WithAttributes(otelAttributes(service, method)...))
+	} else {
+		m.responseSize.Record(context.Background(), int64(bytes), metric.WithAttributes(otelAttributes(service, method)...))
+	}
+}
+
+func (m *OTelMetrics) ReportBytesReceived(callType, service, method string, bytes int) {
+	if m.isClient {
+		m.responseSize.Record(context.Background(), int64(bytes), metric.WithAttributes(otelAttributes(service, method)...))
+	} else {
+		m.requestSize.Record(context.Background(), int64(bytes), metric.WithAttributes(otelAttributes(service, method)...))
+	}
+}
+
+func otelAttributes(service, method string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		otelRPCSystemConnect,
+		otelRPCService.String(service),
+		otelRPCMethod.String(method),
+	}
+}
@@ -0,0 +1,67 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestOTelMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	clientMetrics, err := NewOTelClientMetrics(provider)
+	require.NoError(t, err)
+	serverMetrics, err := NewOTelServerMetrics(provider)
+	require.NoError(t, err)
+
+	interceptor := NewInterceptor(WithClientRecorder(clientMetrics), WithServerRecorder(serverMetrics))
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetconnect.UnimplementedGreetServiceHandler{}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	createClientAndRequest(t, srv, interceptor)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+
+	metrics := make(map[string]metricdata.Metrics)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m
+	}
+	require.Contains(t, metrics, "rpc.client.request.size")
+	require.Contains(t, metrics, "rpc.server.request.size")
+	require.Contains(t, metrics, "rpc.client.active_requests")
+	require.Contains(t, metrics, "rpc.server.active_requests")
+
+	for _, name := range []string{"rpc.client.duration", "rpc.server.duration"} {
+		require.Contains(t, metrics, name)
+		require.Equal(t, "ms", metrics[name].Unit)
+
+		histogram, ok := metrics[name].Data.(metricdata.Histogram[float64])
+		require.True(t, ok)
+		require.Len(t, histogram.DataPoints, 1)
+		require.EqualValues(t, 1, histogram.DataPoints[0].Count)
+
+		attrs := histogram.DataPoints[0].Attributes
+		for key, expected := range map[attribute.Key]string{
+			"rpc.system":                 "connect_rpc",
+			"rpc.service":                greetconnect.GreetServiceName,
+			"rpc.method":                 "Greet",
+			"rpc.connect_rpc.error_code": connect.CodeUnimplemented.String(),
+		} {
+			value, ok := attrs.Value(key)
+			require.True(t, ok, key)
+			require.Equal(t, expected, value.AsString(), key)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultQuantileWindow is the period over which latency quantiles are computed by default.
+	DefaultQuantileWindow = time.Minute
+	// DefaultQuantileAccuracy is the default relative accuracy of the latency quantiles.
+	DefaultQuantileAccuracy = 0.01
+
+	quantileBuckets = 6
+)
+
+// NewLatencyQuantiles creates an in-process estimator of the latency quantiles per procedure over a
+// sliding window, for load-shedding or autoscaling decisions which can't wait for a Prometheus query.
+// Use it with WithLatencyQuantiles.
+//
+// Latencies are kept in a sketch with log-spaced buckets, so that quantiles are within the configured
+// relative accuracy of the observed latencies, in a bounded amount of memory per procedure.
+func NewLatencyQuantiles(opts ...QuantileOption) *LatencyQuantiles {
+	options := evaluateQuantileOptions(&quantileOptions{
+		window:   DefaultQuantileWindow,
+		accuracy: DefaultQuantileAccuracy,
+	}, opts...)
+
+	accuracy := options.accuracy
+	if accuracy <= 0 || accuracy >= 1 {
+		accuracy = DefaultQuantileAccuracy
+	}
+	gamma := (1 + accuracy) / (1 - accuracy)
+
+	return &LatencyQuantiles{
+		window:   options.window,
+		gamma:    gamma,
+		logGamma: math.Log(gamma),
+		now:      time.Now,
+		server:   make(map[string]*windowedSketch),
+		client:   make(map[string]*windowedSketch),
+	}
+}
+
+var _ callObserver = (*LatencyQuantiles)(nil)
+
+type LatencyQuantiles struct {
+	window   time.Duration
+	gamma    float64
+	logGamma float64
+	now      func() time.Time
+
+	mu             sync.Mutex
+	server, client map[string]*windowedSketch
+}
+
+// Server returns the q-quantile, between 0 and 1, of the latency of procedure handled by this server
+// within the window. It returns false when the procedure was not called within the window.
+func (l *LatencyQuantiles) Server(procedure string, q float64) (time.Duration, bool) {
+	return l.quantile(l.server, procedure, q)
+}
+
+// Client returns the q-quantile, between 0 and 1, of the latency of procedure called by this client
+// within the window. It returns false when the procedure was not called within the window.
+func (l *LatencyQuantiles) Client(procedure string, q float64) (time.Duration, bool) {
+	return l.quantile(l.client, procedure, q)
+}
+
+func (l *LatencyQuantiles) quantile(sketches map[string]*windowedSketch, procedure string, q float64) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sketch, ok := sketches[procedure]
+	if !ok {
+		return 0, false
+	}
+	index, ok := sketch.quantile(l.now(), q)
+	if !ok {
+		return 0, false
+	}
+	return l.value(index), true
+}
+
+func (l *LatencyQuantiles) callStarted(context.Context, *call) {}
+
+func (l *LatencyQuantiles) callFinished(_ context.Context, c *call) {
+	sketches := l.server
+	if c.isClient {
+		sketches = l.client
+	}
+	index := l.index(c.duration)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sketch, ok := sketches[c.procedure]
+	if !ok {
+		sketch = &windowedSketch{ring: newRollingWindow[map[int]uint64](l.window, quantileBuckets)}
+		sketches[c.procedure] = sketch
+	}
+	sketch.add(l.now(), index)
+}
+
+// index returns the sketch bucket of d. Bucket i holds the durations in (gamma^(i-1), gamma^i] nanoseconds,
+// and bucket math.MinInt holds zero durations.
+func (l *LatencyQuantiles) index(d time.Duration) int {
+	if d <= 0 {
+		return math.MinInt
+	}
+	return int(math.Ceil(math.Log(float64(d)) / l.logGamma))
+}
+
+// value returns the duration representing bucket i, within the relative accuracy of every duration it holds.
+func (l *LatencyQuantiles) value(i int) time.Duration {
+	if i == math.MinInt {
+		return 0
+	}
+	return time.Duration(2 * math.Pow(l.gamma, float64(i)) / (l.gamma + 1))
+}
+
+// windowedSketch keeps a sketch of the latencies, the count per sketch bucket, in each of a ring of
+// sub-windows spanning the window.
+type windowedSketch struct {
+	ring rollingWindow[map[int]uint64]
+}
+
+func (s *windowedSketch) add(now time.Time, index int) {
+	counts := s.ring.current(now)
+	if *counts == nil {
+		*counts = make(map[int]uint64)
+	}
+	(*counts)[index]++
+}
+
+// quantile returns the sketch bucket holding the q-quantile of the latencies within the window.
+func (s *windowedSketch) quantile(now time.Time, q float64) (int, bool) {
+	counts := make(map[int]uint64)
+	var total uint64
+	s.ring.each(now, func(bucket *map[int]uint64) {
+		for index, count := range *bucket {
+			counts[index] += count
+			total += count
+		}
+	})
+	if total == 0 {
+		return 0, false
+	}
+
+	indexes := make([]int, 0, len(counts))
+	for index := range counts {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	q = math.Max(0, math.Min(1, q))
+	rank := q * float64(total-1)
+	var seen uint64
+	for _, index := range indexes {
+		seen += counts[index]
+		if float64(seen) > rank {
+			return index, true
+		}
+	}
+	return indexes[len(indexes)-1], true
+}
+
+type quantileOptions struct {
+	window   time.Duration
+	accuracy float64
+}
+
+type QuantileOption func(*quantileOptions)
+
+// WithQuantileWindow configures the period over which quantiles are computed, see DefaultQuantileWindow.
+// Latencies expire in steps of a sixth of the window.
+func WithQuantileWindow(window time.Duration) QuantileOption {
+	return func(opts *quantileOptions) {
+		opts.window = window
+	}
+}
+
+// WithQuantileAccuracy configures the relative accuracy of the quantiles, between 0 and 1, see
+// DefaultQuantileAccuracy. A lower value is more accurate, but keeps more buckets per procedure.
+func WithQuantileAccuracy(accuracy float64) QuantileOption {
+	return func(opts *quantileOptions) {
+		opts.accuracy = accuracy
+	}
+}
+
+func evaluateQuantileOptions(defaults *quantileOptions, opts ...QuantileOption) *quantileOptions {
+	for _, opt := range opts {
+		opt(defaults)
+	}
+	return defaults
+}
@@ -0,0 +1,72 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyQuantiles(t *testing.T) {
+	quantiles := NewLatencyQuantiles()
+	interceptor := NewInterceptor(WithClientMetrics(nil), WithServerMetrics(nil), WithLatencyQuantiles(quantiles))
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetconnect.UnimplementedGreetServiceHandler{}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	_, ok := quantiles.Server(greetconnect.GreetServiceGreetProcedure, 0.5)
+	require.False(t, ok)
+
+	createClientAndRequest(t, srv, interceptor)
+
+	server, ok := quantiles.Server(greetconnect.GreetServiceGreetProcedure, 0.99)
+	require.True(t, ok)
+	client, ok := quantiles.Client(greetconnect.GreetServiceGreetProcedure, 0.99)
+	require.True(t, ok)
+	require.Greater(t, client, time.Duration(0))
+	require.GreaterOrEqual(t, client, server, "the client observes the server latency and the round trip")
+}
+
+func TestLatencyQuantiles_Accuracy(t *testing.T) {
+	now := time.Unix(1000, 0)
+	quantiles := NewLatencyQuantiles(WithQuantileWindow(time.Minute), WithQuantileAccuracy(0.01))
+	quantiles.now = func() time.Time { return now }
+
+	finish := func(d time.Duration) {
+		quantiles.callFinished(context.Background(), &call{procedure: "/test.v1.TestService/Test", duration: d})
+	}
+	for i := 1; i <= 1000; i++ {
+		finish(time.Duration(i) * time.Millisecond)
+	}
+
+	for q, expected := range map[float64]time.Duration{
+		0:    time.Millisecond,
+		0.5:  500 * time.Millisecond,
+		0.99: 990 * time.Millisecond,
+		1:    time.Second,
+	} {
+		actual, ok := quantiles.Server("/test.v1.TestService/Test", q)
+		require.True(t, ok)
+		require.InEpsilon(t, expected, actual, 0.02, "quantile %v", q)
+	}
+
+	now = now.Add(45 * time.Second)
+	finish(0)
+	p50, ok := quantiles.Server("/test.v1.TestService/Test", 0.5)
+	require.True(t, ok)
+	require.InEpsilon(t, 500*time.Millisecond, p50, 0.02, "latencies are kept for the window")
+
+	now = now.Add(30 * time.Second)
+	p50, ok = quantiles.Server("/test.v1.TestService/Test", 0.5)
+	require.True(t, ok)
+	require.Equal(t, time.Duration(0), p50, "older latencies expired")
+
+	now = now.Add(time.Minute)
+	_, ok = quantiles.Server("/test.v1.TestService/Test", 0.5)
+	require.False(t, ok)
+}
@@ -0,0 +1,200 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/cockroachdb/errors"
+)
+
+// DefaultRecentErrorsSize is the number of failed calls kept per procedure by default.
+const DefaultRecentErrorsSize = 20
+
+// NewRecentErrors creates a buffer of the last size failed RPCs per procedure and side. Use it with
+// WithRecentErrors, and serve it on a debug endpoint to inspect the errors as HTML, or as JSON with
+// ?format=json. The errors of a single procedure are served with ?procedure=/greet.v1.GreetService/Greet,
+// and those of a single side with ?side=server or ?side=client.
+func NewRecentErrors(size int) *RecentErrors {
+	if size <= 0 {
+		size = DefaultRecentErrorsSize
+	}
+	return &RecentErrors{
+		size:   size,
+		server: make(map[string]*failedCallRing),
+		client: make(map[string]*failedCallRing),
+	}
+}
+
+var (
+	_ callObserver = (*RecentErrors)(nil)
+	_ http.Handler = (*RecentErrors)(nil)
+)
+
+type RecentErrors struct {
+	size int
+
+	mu             sync.Mutex
+	server, client map[string]*failedCallRing
+}
+
+// FailedCall describes a RPC which completed with an error.
+type FailedCall struct {
+	Side      string        `json:"side"`
+	Type      string        `json:"type"`
+	Procedure string        `json:"procedure"`
+	Code      string        `json:"code"`
+	Message   string        `json:"message"`
+	Duration  time.Duration `json:"duration"`
+	Time      time.Time     `json:"time"`
+	Peer      string        `json:"peer"`
+}
+
+// Server returns the recent failed calls of procedure handled by this server, most recent first.
+func (e *RecentErrors) Server(procedure string) []FailedCall {
+	return e.calls(sideOf(false), procedure)
+}
+
+// Client returns the recent failed calls of procedure made by this client, most recent first.
+func (e *RecentErrors) Client(procedure string) []FailedCall {
+	return e.calls(sideOf(true), procedure)
+}
+
+// All returns the recent failed calls of every procedure and side, most recent first.
+func (e *RecentErrors) All() []FailedCall {
+	return e.calls("", "")
+}
+
+// calls returns the recent failed calls of side and procedure, of every side or procedure when empty,
+// most recent first.
+func (e *RecentErrors) calls(side, procedure string) []FailedCall {
+	e.mu.Lock()
+	var calls []FailedCall
+	for _, rings := range []struct {
+		side  string
+		rings map[string]*failedCallRing
+	}{
+		{side: sideOf(false), rings: e.server},
+		{side: sideOf(true), rings: e.client},
+	} {
+		if side != "" && side != rings.side {
+			continue
+		}
+		if procedure != "" {
+			if ring, ok := rings.rings[procedure]; ok {
+				calls = append(calls, ring.list()...)
+			}
+			continue
+		}
+		for _, ring := range rings.rings {
+			calls = append(calls, ring.list()...)
+		}
+	}
+	e.mu.Unlock()
+
+	sort.Slice(calls, func(i, j int) bool {
+		return calls[i].Time.After(calls[j].Time)
+	})
+	return calls
+}
+
+func (e *RecentErrors) callStarted(context.Context, *call) {}
+
+func (e *RecentErrors) callFinished(_ context.Context, c *call) {
+	if c.code == CodeOk {
+		return
+	}
+
+	failed := FailedCall{
+		Side:      sideOf(c.isClient),
+		Type:      c.callType,
+		Procedure: c.procedure,
+		Code:      c.code,
+		Message:   errorMessage(c.err),
+		Duration:  c.duration,
+		Time:      c.start.Add(c.duration),
+		Peer:      c.peer,
+	}
+	if c.panicked {
+		failed.Message = "panic"
+	}
+
+	rings := e.server
+	if c.isClient {
+		rings = e.client
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ring, ok := rings[c.procedure]
+	if !ok {
+		ring = &failedCallRing{calls: make([]FailedCall, e.size)}
+		rings[c.procedure] = ring
+	}
+	ring.add(failed)
+}
+
+var recentErrorsTemplate = template.Must(template.New("errors").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Recent RPC errors</title></head>
+<body>
+<h1>Recent RPC errors ({{len .}})</h1>
+<table border="1" cellpadding="4">
+<tr><th>Time</th><th>Side</th><th>Type</th><th>Procedure</th><th>Code</th><th>Message</th><th>Duration</th><th>Peer</th></tr>
+{{range .}}<tr><td>{{.Time.Format "2006-01-02T15:04:05.000Z07:00"}}</td><td>{{.Side}}</td><td>{{.Type}}</td><td>{{.Procedure}}</td><td>{{.Code}}</td><td>{{.Message}}</td><td>{{.Duration}}</td><td>{{.Peer}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func (e *RecentErrors) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	calls := e.calls(query.Get("side"), query.Get("procedure"))
+	if calls == nil {
+		calls = []FailedCall{}
+	}
+	serveDebug(w, r, recentErrorsTemplate, calls)
+}
+
+// errorMessage returns the message of a connect.Error without its code prefix, or the message of any other error.
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	var connectErr *connect.Error
+	if errors.As(err, &connectErr) {
+		return connectErr.Message()
+	}
+	return err.Error()
+}
+
+// failedCallRing is a fixed size ring buffer, overwriting the oldest call once full.
+type failedCallRing struct {
+	calls []FailedCall
+	next  int
+	full  bool
+}
+
+func (r *failedCallRing) add(call FailedCall) {
+	r.calls[r.next] = call
+	r.next = (r.next + 1) % len(r.calls)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *failedCallRing) list() []FailedCall {
+	n := r.next
+	if r.full {
+		n = len(r.calls)
+	}
+	calls := make([]FailedCall, 0, n)
+	for i := 1; i <= n; i++ {
+		calls = append(calls, r.calls[(r.next-i+len(r.calls))%len(r.calls)])
+	}
+	return calls
+}
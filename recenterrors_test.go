@@ -0,0 +1,69 @@
+package connect_go_prometheus
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecentErrors(t *testing.T) {
+	recentErrors := NewRecentErrors(2)
+	interceptor := NewInterceptor(WithClientMetrics(nil), WithServerMetrics(nil), WithRecentErrors(recentErrors))
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetconnect.UnimplementedGreetServiceHandler{}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	for i := 0; i < 3; i++ {
+		createClientAndRequest(t, srv, interceptor)
+	}
+
+	errs := recentErrors.Server(greetconnect.GreetServiceGreetProcedure)
+	require.Len(t, errs, 2, "only the last 2 errors are kept")
+	require.Equal(t, connect.CodeUnimplemented.String(), errs[0].Code)
+	require.Equal(t, "greet.v1.GreetService.Greet is not implemented", errs[0].Message)
+	require.False(t, errs[0].Time.Before(errs[1].Time), "most recent first")
+	require.Equal(t, "server", errs[0].Side)
+	require.Empty(t, recentErrors.Server(greetconnect.GreetServiceServerStreamGreetProcedure))
+
+	errs = recentErrors.Client(greetconnect.GreetServiceGreetProcedure)
+	require.Len(t, errs, 2, "client-side errors are kept apart from server-side errors")
+	require.Equal(t, "client", errs[0].Side)
+
+	serve := func(query string) []FailedCall {
+		rec := httptest.NewRecorder()
+		recentErrors.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/errors?format=json"+query, nil))
+		var served []FailedCall
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &served))
+		return served
+	}
+	require.Len(t, serve(""), 4)
+	require.Len(t, serve("&procedure="+greetconnect.GreetServiceGreetProcedure), 4)
+	require.Len(t, serve("&side=client"), 2)
+	served := serve("&side=server&procedure=" + greetconnect.GreetServiceGreetProcedure)
+	require.Len(t, served, 2)
+	require.Equal(t, "server", served[0].Side)
+
+	rec := httptest.NewRecorder()
+	recentErrors.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/errors", nil))
+	require.Contains(t, rec.Body.String(), "is not implemented")
+}
+
+func TestFailedCallRing(t *testing.T) {
+	ring := &failedCallRing{calls: make([]FailedCall, 3)}
+	require.Empty(t, ring.list())
+
+	for _, code := range []string{"a", "b", "c", "d"} {
+		ring.add(FailedCall{Code: code})
+	}
+	var codes []string
+	for _, call := range ring.list() {
+		codes = append(codes, call.Code)
+	}
+	require.Equal(t, []string{"d", "c", "b"}, codes)
+}
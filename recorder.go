@@ -0,0 +1,246 @@
+package connect_go_prometheus
+
+import (
+	"google.golang.org/protobuf/proto"
+)
+
+// Recorder records the metrics of RPCs observed by the Interceptor. Metrics is the Prometheus
+// implementation, and is used by default. Implement Recorder to report to other backends, and combine
+// several backends with NewMultiRecorder. Implementations must be safe for concurrent use.
+//
+// Recorders may additionally implement any of ReportPanicked, ReportCanceled, ReportDeadlineBudget,
+// ReportNoDeadline, ReportAttempt and ReportLogicalCall, with the signatures of the corresponding Metrics methods,
+// to receive the optional metrics reported by the Interceptor.
+type Recorder interface {
+	ReportStarted(callType, service, method string)
+	ReportHandled(callType, service, method, code string)
+	ReportHandledSeconds(callType, service, method, code string, val float64)
+	ReportMsgSent(callType, service, method string)
+	ReportMsgReceived(callType, service, method string)
+	ReportBytesSent(callType, service, method string, bytes int)
+	ReportBytesReceived(callType, service, method string, bytes int)
+}
+
+type panicRecorder interface {
+	ReportPanicked(callType, service, method string)
+}
+
+type cancelRecorder interface {
+	ReportCanceled(callType, service, method, code, source string)
+}
+
+type deadlineRecorder interface {
+	ReportDeadlineBudget(callType, service, method string, ratio float64)
+	ReportNoDeadline(callType, service, method string)
+}
+
+type attemptRecorder interface {
+	ReportAttempt(callType, service, method, attempt, code string)
+}
+
+type logicalCallRecorder interface {
+	ReportLogicalCall(callType, service, method, attempts, code string)
+}
+
+// unaryMessageRecorder is implemented by recorders which may also count the messages of unary calls in the
+// stream message metrics, see WithGRPCPrometheusNames.
+type unaryMessageRecorder interface {
+	reportUnaryMsgSent(callType, service, method string)
+	reportUnaryMsgReceived(callType, service, method string)
+}
+
+// bytesRecorder is implemented by recorders which only record bytes when configured to, allowing the
+// Interceptor to skip computing message sizes otherwise.
+type bytesRecorder interface {
+	reportsBytes() bool
+}
+
+func reportsBytes(r Recorder) bool {
+	if br, ok := r.(bytesRecorder); ok {
+		return br.reportsBytes()
+	}
+	return true
+}
+
+func sizeOf(message any) int {
+	if msg, ok := message.(proto.Message); ok {
+		return proto.Size(msg)
+	}
+	return 0
+}
+
+func reportPanicked(r Recorder, callType, service, method string) {
+	if pr, ok := r.(panicRecorder); ok {
+		pr.ReportPanicked(callType, service, method)
+	}
+}
+
+func reportCanceled(r Recorder, callType, service, method, code, source string) {
+	if cr, ok := r.(cancelRecorder); ok {
+		cr.ReportCanceled(callType, service, method, code, source)
+	}
+}
+
+func reportDeadlineBudget(r Recorder, callType, service, method string, ratio float64) {
+	if dr, ok := r.(deadlineRecorder); ok {
+		dr.ReportDeadlineBudget(callType, service, method, ratio)
+	}
+}
+
+func reportNoDeadline(r Recorder, callType, service, method string) {
+	if dr, ok := r.(deadlineRecorder); ok {
+		dr.ReportNoDeadline(callType, service, method)
+	}
+}
+
+func reportAttempt(r Recorder, callType, service, method, attempt, code string) {
+	if ar, ok := r.(attemptRecorder); ok {
+		ar.ReportAttempt(callType, service, method, attempt, code)
+	}
+}
+
+func reportUnaryMsgSent(r Recorder, callType, service, method string) {
+	if ur, ok := r.(unaryMessageRecorder); ok {
+		ur.reportUnaryMsgSent(callType, service, method)
+	}
+}
+
+func reportUnaryMsgReceived(r Recorder, callType, service, method string) {
+	if ur, ok := r.(unaryMessageRecorder); ok {
+		ur.reportUnaryMsgReceived(callType, service, method)
+	}
+}
+
+func reportLogicalCall(r Recorder, callType, service, method, attempts, code string) {
+	if lr, ok := r.(logicalCallRecorder); ok {
+		lr.ReportLogicalCall(callType, service, method, attempts, code)
+	}
+}
+
+// NewMultiRecorder creates a Recorder which dispatches every report to each of the recorders, for
+// example to report to both Prometheus and a StatsD sidecar. Nil recorders are ignored.
+func NewMultiRecorder(recorders ...Recorder) Recorder {
+	r := make(multiRecorder, 0, len(recorders))
+	for _, recorder := range recorders {
+		if recorder != nil {
+			r = append(r, recorder)
+		}
+	}
+	return r
+}
+
+var (
+	_ Recorder             = multiRecorder(nil)
+	_ panicRecorder        = multiRecorder(nil)
+	_ cancelRecorder       = multiRecorder(nil)
+	_ deadlineRecorder     = multiRecorder(nil)
+	_ attemptRecorder      = multiRecorder(nil)
+	_ logicalCallRecorder  = multiRecorder(nil)
+	_ unaryMessageRecorder = multiRecorder(nil)
+	_ bytesRecorder        = multiRecorder(nil)
+)
+
+type multiRecorder []Recorder
+
+func (m multiRecorder) ReportStarted(callType, service, method string) {
+	for _, r := range m {
+		r.ReportStarted(callType, service, method)
+	}
+}
+
+func (m multiRecorder) ReportHandled(callType, service, method, code string) {
+	for _, r := range m {
+		r.ReportHandled(callType, service, method, code)
+	}
+}
+
+func (m multiRecorder) ReportHandledSeconds(callType, service, method, code string, val float64) {
+	for _, r := range m {
+		r.ReportHandledSeconds(callType, service, method, code, val)
+	}
+}
+
+func (m multiRecorder) ReportMsgSent(callType, service, method string) {
+	for _, r := range m {
+		r.ReportMsgSent(callType, service, method)
+	}
+}
+
+func (m multiRecorder) ReportMsgReceived(callType, service, method string) {
+	for _, r := range m {
+		r.ReportMsgReceived(callType, service, method)
+	}
+}
+
+func (m multiRecorder) ReportBytesSent(callType, service, method string, bytes int) {
+	for _, r := range m {
+		if reportsBytes(r) {
+			r.ReportBytesSent(callType, service, method, bytes)
+		}
+	}
+}
+
+func (m multiRecorder) ReportBytesReceived(callType, service, method string, bytes int) {
+	for _, r := range m {
+		if reportsBytes(r) {
+			r.ReportBytesReceived(callType, service, method, bytes)
+		}
+	}
+}
+
+func (m multiRecorder) ReportPanicked(callType, service, method string) {
+	for _, r := range m {
+		reportPanicked(r, callType, service, method)
+	}
+}
+
+func (m multiRecorder) ReportCanceled(callType, service, method, code, source string) {
+	for _, r := range m {
+		reportCanceled(r, callType, service, method, code, source)
+	}
+}
+
+func (m multiRecorder) ReportDeadlineBudget(callType, service, method string, ratio float64) {
+	for _, r := range m {
+		reportDeadlineBudget(r, callType, service, method, ratio)
+	}
+}
+
+func (m multiRecorder) ReportNoDeadline(callType, service, method string) {
+	for _, r := range m {
+		reportNoDeadline(r, callType, service, method)
+	}
+}
+
+func (m multiRecorder) ReportAttempt(callType, service, method, attempt, code string) {
+	for _, r := range m {
+		reportAttempt(r, callType, service, method, attempt, code)
+	}
+}
+
+func (m multiRecorder) ReportLogicalCall(callType, service, method, attempts, code string) {
+	for _, r := range m {
+		reportLogicalCall(r, callType, service, method, attempts, code)
+	}
+}
+
+func (m multiRecorder) reportUnaryMsgSent(callType, service, method string) {
+	for _, r := range m {
+		reportUnaryMsgSent(r, callType, service, method)
+	}
+}
+
+func (m multiRecorder) reportUnaryMsgReceived(callType, service, method string) {
+	for _, r := range m {
+		reportUnaryMsgReceived(r, callType, service, method)
+	}
+}
+
+func (m multiRecorder) reportsBytes() bool {
+	for _, r := range m {
+		if reportsBytes(r) {
+			return true
+		}
+	}
+	return false
+}
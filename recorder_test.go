@@ -0,0 +1,83 @@
+package connect_go_prometheus
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRecorder is a minimal Recorder, implementing none of the optional reports.
+type countingRecorder struct {
+	mu      sync.Mutex
+	started int
+	handled map[string]int
+	bytes   int
+}
+
+func (r *countingRecorder) ReportStarted(callType, service, method string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.started++
+}
+
+func (r *countingRecorder) ReportHandled(callType, service, method, code string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.handled == nil {
+		r.handled = make(map[string]int)
+	}
+	r.handled[code]++
+}
+
+func (r *countingRecorder) ReportHandledSeconds(callType, service, method, code string, val float64) {
+}
+
+func (r *countingRecorder) ReportMsgSent(callType, service, method string) {}
+
+func (r *countingRecorder) ReportMsgReceived(callType, service, method string) {}
+
+func (r *countingRecorder) ReportBytesSent(callType, service, method string, bytes int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bytes += bytes
+}
+
+func (r *countingRecorder) ReportBytesReceived(callType, service, method string, bytes int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bytes += bytes
+}
+
+func TestMultiRecorder(t *testing.T) {
+	serverMetrics := NewServerMetrics(WithDeadlineMetrics(true))
+	counting := &countingRecorder{}
+
+	recorder := NewMultiRecorder(serverMetrics, nil, counting)
+	interceptor := NewInterceptor(WithServerRecorder(recorder), WithClientRecorder(nil))
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetconnect.UnimplementedGreetServiceHandler{}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	createClientAndRequest(t, srv, interceptor)
+
+	require.Equal(t, 1, counting.started)
+	require.Equal(t, map[string]int{connect.CodeUnimplemented.String(): 1}, counting.handled)
+	require.Positive(t, counting.bytes, "bytes are reported as the recorder does not opt out")
+
+	require.EqualValues(t, 1, testutil.ToFloat64(serverMetrics.requestHandled.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet", connect.CodeUnimplemented.String())))
+	require.EqualValues(t, 1, testutil.ToFloat64(serverMetrics.noDeadline.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet")))
+}
+
+func TestReportsBytes(t *testing.T) {
+	require.False(t, reportsBytes(NewServerMetrics()))
+	require.True(t, reportsBytes(NewServerMetrics(WithByteMetrics(true))))
+	require.True(t, reportsBytes(&countingRecorder{}))
+	require.False(t, reportsBytes(NewMultiRecorder(NewServerMetrics(), NewClientMetrics())))
+	require.True(t, reportsBytes(NewMultiRecorder(NewServerMetrics(), &countingRecorder{})))
+}
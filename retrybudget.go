@@ -0,0 +1,247 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+const (
+	// DefaultRetryBudgetRatio allows retries to add up to 20% of the calls made.
+	DefaultRetryBudgetRatio = 0.2
+	// DefaultRetryBudgetWindow is the period over which the retry budget is computed.
+	DefaultRetryBudgetWindow = 10 * time.Second
+)
+
+// NewRetryBudgetInterceptor creates a client-side interceptor which tracks RPCs completing with a
+// retryable code over a rolling window, and reports the fraction of the retry budget remaining per
+// procedure against m. The budget allows a ratio of retryable failures to calls, once it is exhausted
+// the reported fraction drops to 0 and further retries risk a retry storm. The fraction is computed when
+// m is collected, so that it recovers as failures leave the window even when no calls are made.
+//
+// The interceptor only observes calls, it does not retry them or enforce the budget. Use it together
+// with the Interceptor and the same client metrics, so that the gauge is registered with them. Close it
+// once its client is no longer used, so that m stops reporting its budgets.
+func NewRetryBudgetInterceptor(m *Metrics, opts ...RetryBudgetOption) *RetryBudgetInterceptor {
+	options := evaluateRetryBudgetOptions(&retryBudgetOptions{
+		ratio:  DefaultRetryBudgetRatio,
+		window: DefaultRetryBudgetWindow,
+		retryableCodes: []connect.Code{
+			connect.CodeUnavailable,
+			connect.CodeResourceExhausted,
+		},
+	}, opts...)
+
+	retryable := make(map[string]struct{}, len(options.retryableCodes))
+	for _, code := range options.retryableCodes {
+		retryable[code.String()] = struct{}{}
+	}
+
+	i := &RetryBudgetInterceptor{
+		metrics:   m,
+		ratio:     options.ratio,
+		window:    options.window,
+		retryable: retryable,
+		now:       time.Now,
+		budgets:   make(map[string]*retryBudget),
+	}
+	if m != nil {
+		m.retryBudgetsMu.Lock()
+		m.retryBudgets = append(m.retryBudgets, i)
+		m.retryBudgetsMu.Unlock()
+	}
+	return i
+}
+
+var _ connect.Interceptor = (*RetryBudgetInterceptor)(nil)
+
+type RetryBudgetInterceptor struct {
+	metrics   *Metrics
+	ratio     float64
+	window    time.Duration
+	retryable map[string]struct{}
+	now       func() time.Time
+
+	mu      sync.Mutex
+	budgets map[string]*retryBudget
+}
+
+func (i *RetryBudgetInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return connect.UnaryFunc(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if i.metrics == nil || !req.Spec().IsClient {
+			return next(ctx, req)
+		}
+
+		resp, err := next(ctx, req)
+		i.observe(req.Spec().Procedure, codeOf(err))
+		return resp, err
+	})
+}
+
+func (i *RetryBudgetInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return connect.StreamingClientFunc(func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		conn := next(ctx, spec)
+		if i.metrics == nil {
+			return conn
+		}
+		return &retryBudgetClientConn{
+			StreamingClientConn: conn,
+			onClose: func(err error) {
+				i.observe(spec.Procedure, codeOf(err))
+			},
+		}
+	})
+}
+
+func (i *RetryBudgetInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
+
+// Close unregisters the interceptor from its metrics, and removes the gauge series of the procedures it
+// observed. Calls made through it afterwards are no longer reported.
+func (i *RetryBudgetInterceptor) Close() {
+	m := i.metrics
+	if m == nil {
+		return
+	}
+
+	m.retryBudgetsMu.Lock()
+	defer m.retryBudgetsMu.Unlock()
+	m.retryBudgets = slices.DeleteFunc(m.retryBudgets, func(other *RetryBudgetInterceptor) bool {
+		return other == i
+	})
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for procedure := range i.budgets {
+		service, method := procedureToPackageAndMethod(procedure)
+		m.retryBudget.DeleteLabelValues(service, method)
+	}
+	i.budgets = make(map[string]*retryBudget)
+}
+
+// Remaining returns the fraction of the retry budget currently remaining for the procedure.
+func (i *RetryBudgetInterceptor) Remaining(procedure string) float64 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	budget, ok := i.budgets[procedure]
+	if !ok {
+		return 1
+	}
+	return budget.remaining(i.now(), i.ratio)
+}
+
+func (i *RetryBudgetInterceptor) observe(procedure, code string) {
+	_, retryable := i.retryable[code]
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	budget, ok := i.budgets[procedure]
+	if !ok {
+		budget = newRetryBudget(i.window)
+		i.budgets[procedure] = budget
+	}
+	budget.add(i.now(), retryable)
+}
+
+// collectRetryBudgets reports the fraction of the budget remaining now, for every procedure observed by the
+// retry budget interceptors of m.
+func (m *Metrics) collectRetryBudgets() {
+	// Held throughout, so that a closed interceptor's series are not reported again once removed.
+	m.retryBudgetsMu.Lock()
+	defer m.retryBudgetsMu.Unlock()
+
+	for _, i := range m.retryBudgets {
+		i.mu.Lock()
+		now := i.now()
+		remaining := make(map[string]float64, len(i.budgets))
+		for procedure, budget := range i.budgets {
+			remaining[procedure] = budget.remaining(now, i.ratio)
+		}
+		i.mu.Unlock()
+
+		for procedure, r := range remaining {
+			service, method := procedureToPackageAndMethod(procedure)
+			m.ReportRetryBudget(service, method, r)
+		}
+	}
+}
+
+// retryBudget counts calls and retryable failures over the window.
+type retryBudget struct {
+	*rollingCounts
+}
+
+func newRetryBudget(window time.Duration) *retryBudget {
+	return &retryBudget{rollingCounts: newRollingCounts(window)}
+}
+
+func (b *retryBudget) remaining(now time.Time, ratio float64) float64 {
+	calls, retryable := b.counts(now)
+	if calls == 0 || ratio <= 0 {
+		if retryable > 0 {
+			return 0
+		}
+		return 1
+	}
+
+	remaining := 1 - float64(retryable)/(ratio*float64(calls))
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+type retryBudgetClientConn struct {
+	connect.StreamingClientConn
+	onClose func(error)
+}
+
+func (conn *retryBudgetClientConn) CloseResponse() error {
+	err := conn.StreamingClientConn.CloseResponse()
+	conn.onClose(err)
+	return err
+}
+
+type retryBudgetOptions struct {
+	ratio          float64
+	window         time.Duration
+	retryableCodes []connect.Code
+}
+
+type RetryBudgetOption func(*retryBudgetOptions)
+
+// WithRetryBudgetRatio sets the ratio of retryable failures to calls allowed by the budget,
+// defaults to DefaultRetryBudgetRatio.
+func WithRetryBudgetRatio(ratio float64) RetryBudgetOption {
+	return func(opts *retryBudgetOptions) {
+		opts.ratio = ratio
+	}
+}
+
+// WithRetryBudgetWindow sets the period over which the budget is computed, defaults to DefaultRetryBudgetWindow.
+func WithRetryBudgetWindow(window time.Duration) RetryBudgetOption {
+	return func(opts *retryBudgetOptions) {
+		opts.window = window
+	}
+}
+
+// WithRetryableCodes sets the codes which consume the retry budget, defaults to
+// connect.CodeUnavailable and connect.CodeResourceExhausted.
+func WithRetryableCodes(codes ...connect.Code) RetryBudgetOption {
+	return func(opts *retryBudgetOptions) {
+		opts.retryableCodes = codes
+	}
+}
+
+func evaluateRetryBudgetOptions(defaults *retryBudgetOptions, opts ...RetryBudgetOption) *retryBudgetOptions {
+	for _, opt := range opts {
+		opt(defaults)
+	}
+	return defaults
+}
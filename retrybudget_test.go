@@ -0,0 +1,103 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type unavailableGreetServiceHandler struct {
+	greetconnect.UnimplementedGreetServiceHandler
+}
+
+func (unavailableGreetServiceHandler) Greet(context.Context, *connect.Request[greet.GreetRequest]) (*connect.Response[greet.GreetResponse], error) {
+	return nil, connect.NewError(connect.CodeUnavailable, errors.New("overloaded"))
+}
+
+func TestRetryBudgetInterceptor(t *testing.T) {
+	clientMetrics := NewClientMetrics()
+	budget := NewRetryBudgetInterceptor(clientMetrics, WithRetryBudgetRatio(0.5))
+
+	_, handler := greetconnect.NewGreetServiceHandler(unavailableGreetServiceHandler{})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, connect.WithInterceptors(budget))
+	_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "eliza"}))
+	require.Equal(t, connect.CodeUnavailable, connect.CodeOf(err))
+
+	requireRetryBudget(t, clientMetrics, 0)
+	require.EqualValues(t, 0, budget.Remaining(greetconnect.GreetServiceGreetProcedure))
+	require.EqualValues(t, 1, budget.Remaining(greetconnect.GreetServiceServerStreamGreetProcedure))
+
+	// The budget refills once the failure leaves the window, without any further calls.
+	budget.now = func() time.Time { return time.Now().Add(DefaultRetryBudgetWindow + time.Second) }
+	requireRetryBudget(t, clientMetrics, 1)
+}
+
+func TestRetryBudgetInterceptor_Close(t *testing.T) {
+	clientMetrics := NewClientMetrics()
+
+	_, handler := greetconnect.NewGreetServiceHandler(unavailableGreetServiceHandler{})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	// One interceptor per short-lived client.
+	for i := 0; i < 3; i++ {
+		budget := NewRetryBudgetInterceptor(clientMetrics)
+		client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, connect.WithInterceptors(budget))
+		_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "eliza"}))
+		require.Equal(t, connect.CodeUnavailable, connect.CodeOf(err))
+		requireRetryBudget(t, clientMetrics, 0)
+		budget.Close()
+	}
+
+	require.Empty(t, clientMetrics.retryBudgets, "closed interceptors are unregistered")
+	require.Zero(t, testutil.CollectAndCount(clientMetrics, "connect_client_retry_budget_remaining_ratio"), "the budgets of closed interceptors are no longer reported")
+}
+
+// requireRetryBudget checks the retry budget of the Greet procedure collected from m.
+func requireRetryBudget(t *testing.T, m *Metrics, remaining float64) {
+	t.Helper()
+	err := testutil.CollectAndCompare(m, strings.NewReader(fmt.Sprintf(`
+			# HELP connect_client_retry_budget_remaining_ratio Fraction of the retry budget remaining client-side, as observed by the retry budget interceptor
+			# TYPE connect_client_retry_budget_remaining_ratio gauge
+			connect_client_retry_budget_remaining_ratio{method="Greet",service="greet.v1.GreetService"} %g
+		`, remaining)), "connect_client_retry_budget_remaining_ratio")
+	require.NoError(t, err)
+}
+
+func TestRetryBudget(t *testing.T) {
+	now := time.Unix(1000, 0)
+	budget := newRetryBudget(10 * time.Second)
+	require.EqualValues(t, 1, budget.remaining(now, 0.2))
+
+	for i := 0; i < 9; i++ {
+		budget.add(now, false)
+	}
+	budget.add(now, true)
+	require.InDelta(t, 0.5, budget.remaining(now, 0.2), 0.0001, "10% of calls failed against a 20% budget")
+
+	budget.add(now.Add(5*time.Second), true)
+	require.InDelta(t, 1-2/(0.2*11), budget.remaining(now.Add(5*time.Second), 0.2), 0.0001)
+
+	later := now.Add(12 * time.Second)
+	require.InDelta(t, 0, budget.remaining(later, 0.2), 0.0001, "only the failure within the window counts")
+	for i := 0; i < 3; i++ {
+		budget.add(later, false)
+	}
+	require.InDelta(t, 0.5, budget.remaining(later, 0.5), 0.0001)
+
+	require.EqualValues(t, 1, budget.remaining(now.Add(time.Minute), 0.2), "all buckets expired")
+}
@@ -0,0 +1,80 @@
+package connect_go_prometheus
+
+import (
+	"time"
+)
+
+const rollingBuckets = 10
+
+// rollingWindow keeps a value per sub-window in a ring of buckets spanning a window. Values are reset to
+// their zero value when their bucket is reused for a later sub-window.
+type rollingWindow[T any] struct {
+	width   time.Duration
+	buckets []rollingBucket[T]
+}
+
+type rollingBucket[T any] struct {
+	start time.Time
+	value T
+}
+
+// newRollingWindow creates a ring of n buckets spanning window.
+func newRollingWindow[T any](window time.Duration, n int) rollingWindow[T] {
+	width := window / time.Duration(n)
+	if width <= 0 {
+		width = 1
+	}
+	return rollingWindow[T]{width: width, buckets: make([]rollingBucket[T], n)}
+}
+
+// current returns the value of the sub-window of now.
+func (r *rollingWindow[T]) current(now time.Time) *T {
+	start := now.Truncate(r.width)
+	bucket := &r.buckets[(start.UnixNano()/int64(r.width))%int64(len(r.buckets))]
+	if !bucket.start.Equal(start) {
+		*bucket = rollingBucket[T]{start: start}
+	}
+	return &bucket.value
+}
+
+// each calls f with the value of every sub-window within the window of now.
+func (r *rollingWindow[T]) each(now time.Time, f func(*T)) {
+	oldest := now.Truncate(r.width).Add(-r.width * time.Duration(len(r.buckets)-1))
+	for i := range r.buckets {
+		if r.buckets[i].start.Before(oldest) {
+			continue
+		}
+		f(&r.buckets[i].value)
+	}
+}
+
+// rollingCounts counts events, and the events matching a condition, over a window.
+type rollingCounts struct {
+	ring rollingWindow[rollingCount]
+}
+
+type rollingCount struct {
+	total   int
+	matched int
+}
+
+func newRollingCounts(window time.Duration) *rollingCounts {
+	return &rollingCounts{ring: newRollingWindow[rollingCount](window, rollingBuckets)}
+}
+
+func (r *rollingCounts) add(now time.Time, matched bool) {
+	count := r.ring.current(now)
+	count.total++
+	if matched {
+		count.matched++
+	}
+}
+
+// counts returns the events, and the events matching, within the window.
+func (r *rollingCounts) counts(now time.Time) (total, matched int) {
+	r.ring.each(now, func(count *rollingCount) {
+		total += count.total
+		matched += count.matched
+	})
+	return total, matched
+}
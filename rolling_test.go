@@ -0,0 +1,34 @@
+package connect_go_prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollingWindow(t *testing.T) {
+	now := time.Unix(1000, 0)
+	ring := newRollingWindow[int](time.Minute, 6)
+
+	sum := func() int {
+		var total int
+		ring.each(now, func(v *int) { total += *v })
+		return total
+	}
+
+	*ring.current(now) += 1
+	*ring.current(now.Add(5 * time.Second)) += 2
+	require.Equal(t, 3, sum(), "values within a sub-window accumulate")
+
+	now = now.Add(30 * time.Second)
+	*ring.current(now) += 4
+	require.Equal(t, 7, sum())
+
+	now = now.Add(40 * time.Second)
+	require.Equal(t, 4, sum(), "sub-windows older than the window are skipped")
+
+	now = now.Add(time.Minute - 10*time.Second)
+	*ring.current(now) += 8
+	require.Equal(t, 8, sum(), "reused buckets are reset")
+}
@@ -0,0 +1,221 @@
+package connect_go_prometheus
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultRulesWindow is the default range of the rate() in the generated rules.
+	DefaultRulesWindow = "5m"
+	// DefaultRulesFor is the default duration an alert condition must hold before the generated alerts fire.
+	DefaultRulesFor = "10m"
+	// DefaultRulesErrorRatioThreshold is the default error ratio above which the generated error alert fires.
+	DefaultRulesErrorRatioThreshold = 0.05
+	// DefaultRulesLatencyThreshold is the default p99 latency above which the generated latency alert fires.
+	DefaultRulesLatencyThreshold = time.Second
+)
+
+// rulesQuantiles are the latency quantiles recorded by the generated rules, by the name of their record.
+var rulesQuantiles = []struct {
+	name string
+	q    float64
+}{
+	{name: "p50", q: 0.5},
+	{name: "p90", q: 0.9},
+	{name: "p99", q: 0.99},
+}
+
+// ServerRules returns a Prometheus rules file, as YAML, with recording rules for the request rate, error
+// ratio and latency quantiles of each server-side procedure, and alerts on the error ratio and p99 latency.
+// Configure it WithRulesMetricsOptions set to the options of NewServerMetrics, so that the rules use the
+// exact metric names produced. Latency rules are only generated when the histogram is enabled.
+//
+// Errors are the RPCs completing with a code indicating a server-side failure: unknown, deadline_exceeded,
+// internal, unavailable or data_loss.
+func ServerRules(opts ...RulesOption) ([]byte, error) {
+	return generateRules(serverMetricsOptions(), newServerMetrics, "ConnectServer", opts...)
+}
+
+// ClientRules returns a Prometheus rules file for client-side procedures, see ServerRules. Configure it
+// WithRulesMetricsOptions set to the options of NewClientMetrics.
+func ClientRules(opts ...RulesOption) ([]byte, error) {
+	return generateRules(clientMetricsOptions(), newClientMetrics, "ConnectClient", opts...)
+}
+
+func generateRules(defaults *metricsOptions, build func(*metricsOptions) *Metrics, alertPrefix string, opts ...RulesOption) ([]byte, error) {
+	options := evaluateRulesOptions(&rulesOptions{
+		window:              DefaultRulesWindow,
+		forDuration:         DefaultRulesFor,
+		errorRatioThreshold: DefaultRulesErrorRatioThreshold,
+		latencyThreshold:    DefaultRulesLatencyThreshold,
+	}, opts...)
+	config := evaluateMetricsOptions(defaults, options.metricsOptions...)
+	if invalid, _ := validateMetricsOptions(config, build); len(invalid) > 0 {
+		return nil, metricsOptionsError(invalid)
+	}
+
+	handled := prom.BuildFQName(config.namespace, config.subsystem, config.requestHandledName)
+	seconds := prom.BuildFQName(config.namespace, config.subsystem, config.requestHandledSecondsName)
+	handledRecord := strings.TrimSuffix(handled, "_total")
+
+	var errorCodes []string
+	for _, code := range serverErrorCodes() {
+		errorCodes = append(errorCodes, labelValue(config.codeValues, code.String()))
+	}
+
+	w := options.window
+	by := config.serviceLabel + ", " + config.methodLabel
+	procedure := fmt.Sprintf("{{ $labels.%s }}/{{ $labels.%s }}", config.serviceLabel, config.methodLabel)
+	rateRecord := fmt.Sprintf("service_method:%s:rate%s", handledRecord, w)
+	errorRatioRecord := fmt.Sprintf("service_method:%s:error_ratio_rate%s", handledRecord, w)
+	recording := ruleGroup{
+		Name: handledRecord + "_recording",
+		Rules: []rule{
+			{
+				Record: rateRecord,
+				Expr:   fmt.Sprintf("sum by (%s) (rate(%s[%s]))", by, handled, w),
+			},
+			{
+				Record: errorRatioRecord,
+				Expr: fmt.Sprintf(`sum by (%s) (rate(%s{%s=~"%s"}[%s])) / sum by (%s) (rate(%s[%s]))`,
+					by, handled, config.codeLabel, strings.Join(errorCodes, "|"), w, by, handled, w),
+			},
+		},
+	}
+	alerting := ruleGroup{
+		Name: handledRecord + "_alerts",
+		Rules: []rule{
+			{
+				Alert: alertPrefix + "HighErrorRatio",
+				Expr:  fmt.Sprintf("%s > %g", errorRatioRecord, options.errorRatioThreshold),
+				For:   options.forDuration,
+				Labels: map[string]string{
+					"severity": "warning",
+				},
+				Annotations: map[string]string{
+					"summary": fmt.Sprintf("%s is failing more than %g%% of RPCs", procedure, options.errorRatioThreshold*100),
+				},
+			},
+		},
+	}
+
+	if config.withHistogram {
+		var p99Record string
+		for _, quantile := range rulesQuantiles {
+			record := fmt.Sprintf("service_method:%s:%s_rate%s", seconds, quantile.name, w)
+			if quantile.name == "p99" {
+				p99Record = record
+			}
+			recording.Rules = append(recording.Rules, rule{
+				Record: record,
+				Expr:   fmt.Sprintf("histogram_quantile(%g, sum by (%s, le) (rate(%s_bucket[%s])))", quantile.q, by, seconds, w),
+			})
+		}
+		alerting.Rules = append(alerting.Rules, rule{
+			Alert: alertPrefix + "HighLatency",
+			Expr:  fmt.Sprintf("%s > %g", p99Record, options.latencyThreshold.Seconds()),
+			For:   options.forDuration,
+			Labels: map[string]string{
+				"severity": "warning",
+			},
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("%s p99 latency is above %s", procedure, options.latencyThreshold),
+			},
+		})
+	}
+
+	return marshalRules(recording, alerting)
+}
+
+// marshalRules returns groups as a Prometheus rules file.
+func marshalRules(groups ...ruleGroup) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(ruleGroups{Groups: groups}); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal rules")
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal rules")
+	}
+	return buf.Bytes(), nil
+}
+
+// ruleGroups is the format of a Prometheus rules file.
+type ruleGroups struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+type rulesOptions struct {
+	metricsOptions      []MetricsOption
+	window              string
+	forDuration         string
+	errorRatioThreshold float64
+	latencyThreshold    time.Duration
+}
+
+type RulesOption func(*rulesOptions)
+
+// WithRulesMetricsOptions configures the metrics the rules are generated for, with the same options as
+// NewServerMetrics and NewClientMetrics.
+func WithRulesMetricsOptions(opts ...MetricsOption) RulesOption {
+	return func(o *rulesOptions) {
+		o.metricsOptions = append(o.metricsOptions, opts...)
+	}
+}
+
+// WithRulesWindow sets the range of the rate() in the rules, such as "1m", defaults to DefaultRulesWindow.
+func WithRulesWindow(window string) RulesOption {
+	return func(o *rulesOptions) {
+		o.window = window
+	}
+}
+
+// WithRulesFor sets how long an alert condition must hold before firing, defaults to DefaultRulesFor.
+func WithRulesFor(d string) RulesOption {
+	return func(o *rulesOptions) {
+		o.forDuration = d
+	}
+}
+
+// WithRulesErrorRatioThreshold sets the error ratio alerted on, defaults to DefaultRulesErrorRatioThreshold.
+func WithRulesErrorRatioThreshold(threshold float64) RulesOption {
+	return func(o *rulesOptions) {
+		o.errorRatioThreshold = threshold
+	}
+}
+
+// WithRulesLatencyThreshold sets the p99 latency alerted on, defaults to DefaultRulesLatencyThreshold.
+func WithRulesLatencyThreshold(threshold time.Duration) RulesOption {
+	return func(o *rulesOptions) {
+		o.latencyThreshold = threshold
+	}
+}
+
+func evaluateRulesOptions(defaults *rulesOptions, opts ...RulesOption) *rulesOptions {
+	for _, opt := range opts {
+		opt(defaults)
+	}
+	return defaults
+}
@@ -0,0 +1,91 @@
+package connect_go_prometheus
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestServerRules(t *testing.T) {
+	metricsOptions := []MetricsOption{WithNamespace("acme"), WithSubsystem("api"), WithHistogram(true)}
+	out, err := ServerRules(
+		WithRulesMetricsOptions(metricsOptions...),
+		WithRulesWindow("1m"),
+		WithRulesLatencyThreshold(250*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	var rules ruleGroups
+	require.NoError(t, yaml.Unmarshal(out, &rules))
+	require.Len(t, rules.Groups, 2)
+
+	recording := rules.Groups[0]
+	require.Len(t, recording.Rules, 5)
+	require.Equal(t, "service_method:acme_api_connect_server_handled:rate1m", recording.Rules[0].Record)
+	require.Equal(t, "sum by (service, method) (rate(acme_api_connect_server_handled_total[1m]))", recording.Rules[0].Expr)
+	require.Equal(t, "service_method:acme_api_connect_server_handled_seconds:p99_rate1m", recording.Rules[4].Record)
+
+	alerts := rules.Groups[1]
+	require.Len(t, alerts.Rules, 2)
+	require.Equal(t, "ConnectServerHighErrorRatio", alerts.Rules[0].Alert)
+	require.Equal(t, "service_method:acme_api_connect_server_handled:error_ratio_rate1m > 0.05", alerts.Rules[0].Expr)
+	require.Equal(t, "ConnectServerHighLatency", alerts.Rules[1].Alert)
+	require.Equal(t, "service_method:acme_api_connect_server_handled_seconds:p99_rate1m > 0.25", alerts.Rules[1].Expr)
+
+	requireRulesUseMetricsOf(t, NewServerMetrics(metricsOptions...), rules)
+}
+
+func TestClientRules_WithoutHistogram(t *testing.T) {
+	out, err := ClientRules()
+	require.NoError(t, err)
+
+	var rules ruleGroups
+	require.NoError(t, yaml.Unmarshal(out, &rules))
+	require.Len(t, rules.Groups[0].Rules, 2, "no latency quantiles without the histogram")
+	require.Len(t, rules.Groups[1].Rules, 1)
+	require.Equal(t, "ConnectClientHighErrorRatio", rules.Groups[1].Rules[0].Alert)
+
+	requireRulesUseMetricsOf(t, NewClientMetrics(), rules)
+}
+
+var rulesMetricName = regexp.MustCompile(`rate\(([a-zA-Z_:][a-zA-Z0-9_:]*?)(_bucket)?[\[{]`)
+
+// requireRulesUseMetricsOf checks that every metric queried by the rules is produced by m.
+func requireRulesUseMetricsOf(t *testing.T, m *Metrics, rules ruleGroups) {
+	t.Helper()
+
+	names := describedNames(m)
+
+	var queried int
+	for _, group := range rules.Groups {
+		for _, r := range group.Rules {
+			for _, match := range rulesMetricName.FindAllStringSubmatch(r.Expr, -1) {
+				require.True(t, names[match[1]], "%s is not produced by the metrics", match[1])
+				queried++
+			}
+		}
+	}
+	require.NotZero(t, queried)
+}
+
+func TestRules_InvalidOptions(t *testing.T) {
+	_, err := ServerRules(WithRulesMetricsOptions(WithNamespace("my-app")))
+	require.ErrorContains(t, err, `namespace "my-app"`)
+}
+
+func TestServerRules_GRPCPrometheusNames(t *testing.T) {
+	out, err := ServerRules(WithRulesMetricsOptions(WithGRPCPrometheusNames(), WithHistogram(true)))
+	require.NoError(t, err)
+
+	var rules ruleGroups
+	require.NoError(t, yaml.Unmarshal(out, &rules))
+	recording := rules.Groups[0]
+	require.Equal(t, "sum by (grpc_service, grpc_method) (rate(grpc_server_handled_total[5m]))", recording.Rules[0].Expr)
+	require.Equal(t, `sum by (grpc_service, grpc_method) (rate(grpc_server_handled_total{grpc_code=~"Unknown|DeadlineExceeded|Internal|Unavailable|DataLoss"}[5m])) / sum by (grpc_service, grpc_method) (rate(grpc_server_handled_total[5m]))`, recording.Rules[1].Expr)
+	require.Contains(t, rules.Groups[1].Rules[0].Annotations["summary"], "{{ $labels.grpc_service }}/{{ $labels.grpc_method }}")
+
+	requireRulesUseMetricsOf(t, NewServerMetrics(WithGRPCPrometheusNames(), WithHistogram(true)), rules)
+}
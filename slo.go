@@ -0,0 +1,157 @@
+package connect_go_prometheus
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/cockroachdb/errors"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// SLO is a service level objective for the RPCs of a procedure, see WithSLOs. A RPC meets it when it
+// completes within LatencyThreshold, and without a code indicating a server-side failure: unknown,
+// deadline_exceeded, internal, unavailable or data_loss.
+type SLO struct {
+	// Procedure is the full name of the procedure, such as /greet.v1.GreetService/Greet.
+	Procedure string
+	// LatencyThreshold is the latency under which a RPC is good. Zero means any latency is good.
+	LatencyThreshold time.Duration
+	// Availability is the target ratio of good RPCs, such as 0.999.
+	Availability float64
+}
+
+// invalid returns why the SLO is invalid, or an empty string when it is valid.
+func (s SLO) invalid() string {
+	if s.Availability <= 0 || s.Availability >= 1 {
+		return fmt.Sprintf("availability of the SLO of %s must be between 0 and 1, got %v", s.Procedure, s.Availability)
+	}
+	return ""
+}
+
+func (s SLO) good(code string, seconds float64) bool {
+	if _, ok := serverErrorCodeSet[code]; ok {
+		return false
+	}
+	return s.LatencyThreshold <= 0 || seconds <= s.LatencyThreshold.Seconds()
+}
+
+// serverErrorCodes returns the codes indicating a failure of the server, rather than of the client.
+func serverErrorCodes() []connect.Code {
+	return []connect.Code{
+		connect.CodeUnknown,
+		connect.CodeDeadlineExceeded,
+		connect.CodeInternal,
+		connect.CodeUnavailable,
+		connect.CodeDataLoss,
+	}
+}
+
+// serverErrorCodeSet holds the names of serverErrorCodes, to classify the code of every RPC without allocating.
+var serverErrorCodeSet = func() map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, code := range serverErrorCodes() {
+		set[code.String()] = struct{}{}
+	}
+	return set
+}()
+
+// burnRateAlert is a multi-window burn rate alert, which fires when the error budget burns at least factor
+// times faster than sustainable over both the long and short windows.
+type burnRateAlert struct {
+	long, short string
+	factor      float64
+	severity    string
+}
+
+// burnRateAlerts are the windows recommended by the Google SRE workbook for a 30 day SLO period.
+var burnRateAlerts = []burnRateAlert{
+	{long: "1h", short: "5m", factor: 14.4, severity: "page"},
+	{long: "6h", short: "30m", factor: 6, severity: "page"},
+	{long: "1d", short: "2h", factor: 3, severity: "ticket"},
+	{long: "3d", short: "6h", factor: 1, severity: "ticket"},
+}
+
+// SLORules returns a Prometheus rules file, as YAML, with recording rules for the error ratio of the SLO
+// counters over each burn rate window, and multi-window burn rate alerts for each of slos. The options
+// must match those of the server metrics, so that the rules use the same metric names.
+func SLORules(slos []SLO, opts ...MetricsOption) ([]byte, error) {
+	config := evaluateMetricsOptions(serverMetricsOptions(), opts...)
+	if invalid, _ := validateMetricsOptions(config, newServerMetrics); len(invalid) > 0 {
+		return nil, metricsOptionsError(invalid)
+	}
+	good := prom.BuildFQName(config.namespace, config.subsystem, config.sloGoodName)
+	total := prom.BuildFQName(config.namespace, config.subsystem, config.sloTotalName)
+	prefix := strings.TrimSuffix(total, "_total")
+	by := config.serviceLabel + ", " + config.methodLabel
+
+	windows := make(map[string]struct{})
+	for _, alert := range burnRateAlerts {
+		windows[alert.long] = struct{}{}
+		windows[alert.short] = struct{}{}
+	}
+	sortedWindows := make([]string, 0, len(windows))
+	for window := range windows {
+		sortedWindows = append(sortedWindows, window)
+	}
+	sort.Slice(sortedWindows, func(i, j int) bool {
+		return windowDuration(sortedWindows[i]) < windowDuration(sortedWindows[j])
+	})
+
+	recording := ruleGroup{Name: prefix + "_recording"}
+	for _, window := range sortedWindows {
+		recording.Rules = append(recording.Rules, rule{
+			Record: fmt.Sprintf("%s:error_ratio:rate%s", prefix, window),
+			Expr: fmt.Sprintf("1 - (sum by (%s) (rate(%s[%s])) / sum by (%s) (rate(%s[%s])))",
+				by, good, window, by, total, window),
+		})
+	}
+
+	alerting := ruleGroup{Name: prefix + "_alerts"}
+	for _, slo := range slos {
+		if problem := slo.invalid(); problem != "" {
+			return nil, errors.New(problem)
+		}
+		service, method := procedureToPackageAndMethod(slo.Procedure)
+		selector := fmt.Sprintf(`{%s=%q, %s=%q}`, config.serviceLabel, service, config.methodLabel, method)
+		budget := 1 - slo.Availability
+
+		for _, severity := range []string{"page", "ticket"} {
+			var conditions []string
+			for _, alert := range burnRateAlerts {
+				if alert.severity != severity {
+					continue
+				}
+				threshold := fmt.Sprintf("(%g * %.6g)", alert.factor, budget)
+				conditions = append(conditions, fmt.Sprintf("(%s:error_ratio:rate%s%s > %s and %s:error_ratio:rate%s%s > %s)",
+					prefix, alert.long, selector, threshold, prefix, alert.short, selector, threshold))
+			}
+			alerting.Rules = append(alerting.Rules, rule{
+				Alert: "ConnectSLOErrorBudgetBurn",
+				Expr:  strings.Join(conditions, " or "),
+				Labels: map[string]string{
+					config.serviceLabel: service,
+					config.methodLabel:  method,
+					"severity":          severity,
+				},
+				Annotations: map[string]string{
+					"summary": fmt.Sprintf("%s is burning its error budget for a %.6g%% availability SLO too fast", slo.Procedure, slo.Availability*100),
+				},
+			})
+		}
+	}
+
+	return marshalRules(recording, alerting)
+}
+
+func windowDuration(window string) time.Duration {
+	if strings.HasSuffix(window, "d") {
+		var days int
+		_, _ = fmt.Sscanf(window, "%dd", &days)
+		return time.Duration(days) * 24 * time.Hour
+	}
+	d, _ := time.ParseDuration(window)
+	return d
+}
@@ -0,0 +1,83 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSLOMetrics(t *testing.T) {
+	serverMetrics := NewServerMetrics(WithSLOs(
+		SLO{Procedure: greetconnect.GreetServiceGreetProcedure, LatencyThreshold: time.Millisecond, Availability: 0.99},
+	))
+	interceptor := NewInterceptor(WithClientMetrics(nil), WithServerMetrics(serverMetrics))
+
+	_, handler := greetconnect.NewGreetServiceHandler(slowGreetServiceHandler{delay: 10 * time.Millisecond}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	require.EqualValues(t, 0, testutil.ToFloat64(serverMetrics.sloTotal.WithLabelValues(greetconnect.GreetServiceName, "Greet")), "initialized to zero")
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL)
+	_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "eliza"}))
+	require.NoError(t, err)
+
+	require.EqualValues(t, 1, testutil.ToFloat64(serverMetrics.sloTotal.WithLabelValues(greetconnect.GreetServiceName, "Greet")))
+	require.EqualValues(t, 0, testutil.ToFloat64(serverMetrics.sloGood.WithLabelValues(greetconnect.GreetServiceName, "Greet")), "slower than the latency threshold")
+	require.Equal(t, 2, testutil.CollectAndCount(serverMetrics, "connect_slo_good_total", "connect_slo_total"), "procedures without a SLO are not counted")
+}
+
+func TestSLO_Good(t *testing.T) {
+	slo := SLO{LatencyThreshold: time.Second}
+	require.True(t, slo.good(CodeOk, 0.5))
+	require.True(t, slo.good(connect.CodeInvalidArgument.String(), 0.5), "client errors don't count against the SLO")
+	require.False(t, slo.good(connect.CodeUnavailable.String(), 0.5))
+	require.False(t, slo.good(CodeOk, 1.5))
+	require.True(t, SLO{}.good(CodeOk, 100), "no latency threshold")
+}
+
+func TestSLORules(t *testing.T) {
+	out, err := SLORules([]SLO{
+		{Procedure: greetconnect.GreetServiceGreetProcedure, Availability: 0.999},
+	}, WithNamespace("acme"))
+	require.NoError(t, err)
+
+	var rules ruleGroups
+	require.NoError(t, yaml.Unmarshal(out, &rules))
+	require.Len(t, rules.Groups, 2)
+
+	recording := rules.Groups[0]
+	require.Len(t, recording.Rules, 7, "one per distinct window")
+	require.Equal(t, "acme_connect_slo:error_ratio:rate5m", recording.Rules[0].Record)
+	require.Equal(t, "acme_connect_slo:error_ratio:rate3d", recording.Rules[6].Record)
+	require.Equal(t, "1 - (sum by (service, method) (rate(acme_connect_slo_good_total[5m])) / sum by (service, method) (rate(acme_connect_slo_total[5m])))", recording.Rules[0].Expr)
+
+	alerts := rules.Groups[1]
+	require.Len(t, alerts.Rules, 2)
+	require.Equal(t, "page", alerts.Rules[0].Labels["severity"])
+	require.Equal(t, `(acme_connect_slo:error_ratio:rate1h{service="greet.v1.GreetService", method="Greet"} > (14.4 * 0.001) and acme_connect_slo:error_ratio:rate5m{service="greet.v1.GreetService", method="Greet"} > (14.4 * 0.001))`+
+		` or (acme_connect_slo:error_ratio:rate6h{service="greet.v1.GreetService", method="Greet"} > (6 * 0.001) and acme_connect_slo:error_ratio:rate30m{service="greet.v1.GreetService", method="Greet"} > (6 * 0.001))`,
+		alerts.Rules[0].Expr)
+	require.Equal(t, "ticket", alerts.Rules[1].Labels["severity"])
+
+	_, err = SLORules([]SLO{{Procedure: greetconnect.GreetServiceGreetProcedure, Availability: 99.9}})
+	require.Error(t, err)
+
+	out, err = SLORules([]SLO{
+		{Procedure: greetconnect.GreetServiceGreetProcedure, Availability: 0.999},
+	}, WithLabelNames(LabelNames{Service: "grpc_service", Method: "grpc_method"}))
+	require.NoError(t, err)
+	require.NoError(t, yaml.Unmarshal(out, &rules))
+	require.Contains(t, rules.Groups[0].Rules[0].Expr, "sum by (grpc_service, grpc_method)")
+	require.Contains(t, rules.Groups[1].Rules[0].Expr, `{grpc_service="greet.v1.GreetService", grpc_method="Greet"}`)
+	require.Equal(t, "Greet", rules.Groups[1].Rules[0].Labels["grpc_method"])
+}
@@ -0,0 +1,127 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const redactedHeaderValue = "[REDACTED]"
+
+// DefaultRedactedHeaders are the request headers whose values are never logged by the slow call logger.
+var DefaultRedactedHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Proxy-Authorization",
+	"X-Api-Key",
+}
+
+// NewSlowCallLogger creates a logger writing a record to logger for every RPC or stream which took longer
+// than threshold to complete, with its request headers, code and message counts. Thresholds can be set per
+// procedure with WithSlowCallThreshold. Use it with WithSlowCallLogger.
+func NewSlowCallLogger(logger *slog.Logger, threshold time.Duration, opts ...SlowCallLogOption) *SlowCallLogger {
+	options := evaluateSlowCallLogOptions(&slowCallLogOptions{
+		level:      slog.LevelWarn,
+		thresholds: make(map[string]time.Duration),
+	}, opts...)
+
+	redacted := make(map[string]struct{}, len(DefaultRedactedHeaders)+len(options.redactedHeaders))
+	for _, name := range DefaultRedactedHeaders {
+		redacted[http.CanonicalHeaderKey(name)] = struct{}{}
+	}
+	for _, name := range options.redactedHeaders {
+		redacted[http.CanonicalHeaderKey(name)] = struct{}{}
+	}
+
+	return &SlowCallLogger{
+		logger:     logger,
+		level:      options.level,
+		threshold:  threshold,
+		thresholds: options.thresholds,
+		redacted:   redacted,
+	}
+}
+
+var _ callObserver = (*SlowCallLogger)(nil)
+
+type SlowCallLogger struct {
+	logger     *slog.Logger
+	level      slog.Level
+	threshold  time.Duration
+	thresholds map[string]time.Duration
+	redacted   map[string]struct{}
+}
+
+func (l *SlowCallLogger) callStarted(context.Context, *call) {}
+
+func (l *SlowCallLogger) callFinished(ctx context.Context, c *call) {
+	threshold, ok := l.thresholds[c.procedure]
+	if !ok {
+		threshold = l.threshold
+	}
+	if threshold <= 0 || c.duration < threshold || !l.logger.Enabled(ctx, l.level) {
+		return
+	}
+
+	msg := "slow server call"
+	if c.isClient {
+		msg = "slow client call"
+	}
+	attrs := append(callAttrs(c),
+		slog.Duration("threshold", threshold),
+		l.headerAttr(c.header),
+	)
+	l.logger.LogAttrs(ctx, l.level, msg, attrs...)
+}
+
+// headerAttr returns the request headers as a group, with the values of sensitive headers redacted.
+func (l *SlowCallLogger) headerAttr(header http.Header) slog.Attr {
+	attrs := make([]any, 0, len(header))
+	for name, values := range header {
+		value := strings.Join(values, ", ")
+		if _, ok := l.redacted[http.CanonicalHeaderKey(name)]; ok {
+			value = redactedHeaderValue
+		}
+		attrs = append(attrs, slog.String(name, value))
+	}
+	return slog.Group("request_header", attrs...)
+}
+
+type slowCallLogOptions struct {
+	level           slog.Level
+	thresholds      map[string]time.Duration
+	redactedHeaders []string
+}
+
+type SlowCallLogOption func(*slowCallLogOptions)
+
+// WithSlowCallThreshold overrides the threshold for a procedure, such as "/greet.v1.GreetService/Greet".
+// A threshold of 0 disables logging of the procedure.
+func WithSlowCallThreshold(procedure string, threshold time.Duration) SlowCallLogOption {
+	return func(opts *slowCallLogOptions) {
+		opts.thresholds[procedure] = threshold
+	}
+}
+
+// WithRedactedHeaders redacts the values of the given request headers, in addition to DefaultRedactedHeaders.
+func WithRedactedHeaders(names ...string) SlowCallLogOption {
+	return func(opts *slowCallLogOptions) {
+		opts.redactedHeaders = append(opts.redactedHeaders, names...)
+	}
+}
+
+// WithSlowCallLevel sets the level slow calls are logged at, defaults to slog.LevelWarn.
+func WithSlowCallLevel(level slog.Level) SlowCallLogOption {
+	return func(opts *slowCallLogOptions) {
+		opts.level = level
+	}
+}
+
+func evaluateSlowCallLogOptions(defaults *slowCallLogOptions, opts ...SlowCallLogOption) *slowCallLogOptions {
+	for _, opt := range opts {
+		opt(defaults)
+	}
+	return defaults
+}
@@ -0,0 +1,66 @@
+package connect_go_prometheus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	"github.com/stretchr/testify/require"
+)
+
+type slowGreetServiceHandler struct {
+	greetconnect.UnimplementedGreetServiceHandler
+	delay time.Duration
+}
+
+func (h slowGreetServiceHandler) Greet(context.Context, *connect.Request[greet.GreetRequest]) (*connect.Response[greet.GreetResponse], error) {
+	time.Sleep(h.delay)
+	return connect.NewResponse(&greet.GreetResponse{Greeting: "hello"}), nil
+}
+
+func TestSlowCallLogger(t *testing.T) {
+	var buf bytes.Buffer
+	slowCallLogger := NewSlowCallLogger(slog.New(slog.NewJSONHandler(&buf, nil)), time.Hour,
+		WithSlowCallThreshold(greetconnect.GreetServiceGreetProcedure, 10*time.Millisecond),
+		WithRedactedHeaders("X-Secret"),
+	)
+	interceptor := NewInterceptor(WithClientMetrics(nil), WithServerMetrics(nil), WithSlowCallLogger(slowCallLogger))
+
+	_, handler := greetconnect.NewGreetServiceHandler(slowGreetServiceHandler{delay: 20 * time.Millisecond}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL)
+	req := connect.NewRequest(&greet.GreetRequest{Name: "eliza"})
+	req.Header().Set("Authorization", "Bearer token")
+	req.Header().Set("X-Secret", "secret")
+	req.Header().Set("X-Request-Id", "1234")
+	_, err := client.Greet(context.Background(), req)
+	require.NoError(t, err)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	require.Equal(t, "slow server call", record["msg"])
+	require.Equal(t, "WARN", record["level"])
+	require.Equal(t, CodeOk, record["code"])
+	require.EqualValues(t, 1, record["msg_received"])
+	require.EqualValues(t, 1, record["msg_sent"])
+
+	header := record["request_header"].(map[string]any)
+	require.Equal(t, redactedHeaderValue, header["Authorization"])
+	require.Equal(t, redactedHeaderValue, header["X-Secret"])
+	require.Equal(t, "1234", header["X-Request-Id"])
+
+	// Streams use the default threshold, which is not exceeded.
+	buf.Reset()
+	createClientAndStreamRequest(t, srv, interceptor)
+	require.Empty(t, buf.String())
+}
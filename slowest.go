@@ -0,0 +1,238 @@
+package connect_go_prometheus
+
+import (
+	"container/heap"
+	"context"
+	"html/template"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSlowestCallsSize is the number of slowest calls kept per procedure by default.
+	DefaultSlowestCallsSize = 10
+	// DefaultSlowestCallsWindow is the period over which the slowest calls are kept by default.
+	DefaultSlowestCallsWindow = 5 * time.Minute
+)
+
+// NewSlowestCalls creates a tracker of the n slowest RPCs per procedure and side which completed within the
+// last window. Use it with WithSlowestCalls, and serve it on a debug endpoint to inspect the outliers
+// behind the tail latency as HTML, or as JSON with ?format=json. The calls of a single procedure are
+// served with ?procedure=/greet.v1.GreetService/Greet, and those of a single side with ?side=server or
+// ?side=client. Client-side latencies include the network and server time, so the sides are kept apart.
+//
+// The window is divided in sub-windows which each keep their n slowest calls, so that once the slowest
+// calls expire, the slowest of those completed since are still kept.
+func NewSlowestCalls(n int, window time.Duration) *SlowestCalls {
+	if n <= 0 {
+		n = DefaultSlowestCallsSize
+	}
+	if window <= 0 {
+		window = DefaultSlowestCallsWindow
+	}
+	return &SlowestCalls{
+		n:      n,
+		window: window,
+		now:    time.Now,
+		server: make(map[string]*slowestWindow),
+		client: make(map[string]*slowestWindow),
+	}
+}
+
+var (
+	_ callObserver = (*SlowestCalls)(nil)
+	_ http.Handler = (*SlowestCalls)(nil)
+)
+
+type SlowestCalls struct {
+	n      int
+	window time.Duration
+	now    func() time.Time
+
+	mu             sync.Mutex
+	server, client map[string]*slowestWindow
+}
+
+// CompletedCall describes a RPC which has completed.
+type CompletedCall struct {
+	Side      string        `json:"side"`
+	Type      string        `json:"type"`
+	Procedure string        `json:"procedure"`
+	Code      string        `json:"code"`
+	Duration  time.Duration `json:"duration"`
+	Start     time.Time     `json:"start"`
+	Peer      string        `json:"peer"`
+}
+
+func (c CompletedCall) end() time.Time {
+	return c.Start.Add(c.Duration)
+}
+
+// Server returns the slowest calls of procedure handled by this server within the window, slowest first.
+func (s *SlowestCalls) Server(procedure string) []CompletedCall {
+	return s.calls(sideOf(false), procedure)
+}
+
+// Client returns the slowest calls of procedure made by this client within the window, slowest first.
+func (s *SlowestCalls) Client(procedure string) []CompletedCall {
+	return s.calls(sideOf(true), procedure)
+}
+
+// All returns the slowest calls of every procedure and side completed within the window, slowest first.
+func (s *SlowestCalls) All() []CompletedCall {
+	return s.calls("", "")
+}
+
+// calls returns the slowest calls of side and procedure, of every side or procedure when empty, slowest first.
+func (s *SlowestCalls) calls(side, procedure string) []CompletedCall {
+	now := s.now()
+
+	s.mu.Lock()
+	var calls []CompletedCall
+	for _, windows := range []struct {
+		side    string
+		windows map[string]*slowestWindow
+	}{
+		{side: sideOf(false), windows: s.server},
+		{side: sideOf(true), windows: s.client},
+	} {
+		if side != "" && side != windows.side {
+			continue
+		}
+		for p, w := range windows.windows {
+			if procedure != "" && procedure != p {
+				continue
+			}
+			slowest := w.slowest(now, s.n)
+			if len(slowest) == 0 {
+				delete(windows.windows, p)
+			}
+			calls = append(calls, slowest...)
+		}
+	}
+	s.mu.Unlock()
+
+	sortSlowestFirst(calls)
+	return calls
+}
+
+func (s *SlowestCalls) callStarted(context.Context, *call) {}
+
+func (s *SlowestCalls) callFinished(_ context.Context, c *call) {
+	completed := CompletedCall{
+		Side:      sideOf(c.isClient),
+		Type:      c.callType,
+		Procedure: c.procedure,
+		Code:      c.code,
+		Duration:  c.duration,
+		Start:     c.start,
+		Peer:      c.peer,
+	}
+
+	windows := s.server
+	if c.isClient {
+		windows = s.client
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w, ok := windows[c.procedure]
+	if !ok {
+		w = newSlowestWindow(s.window)
+		windows[c.procedure] = w
+	}
+	w.add(s.now(), completed, s.n)
+}
+
+// slowestWindow keeps the slowest calls of a procedure in a ring of sub-windows spanning the window,
+// each a heap of the slowest calls completed within it.
+type slowestWindow struct {
+	window time.Duration
+	ring   rollingWindow[callHeap]
+}
+
+func newSlowestWindow(window time.Duration) *slowestWindow {
+	return &slowestWindow{window: window, ring: newRollingWindow[callHeap](window, rollingBuckets)}
+}
+
+// add keeps c when it is one of the n slowest calls of its sub-window.
+func (w *slowestWindow) add(now time.Time, c CompletedCall, n int) {
+	h := w.ring.current(now)
+	if h.Len() < n {
+		heap.Push(h, c)
+		return
+	}
+	// The heap is full, the new call only makes it in when slower than the fastest one kept.
+	if (*h)[0].Duration < c.Duration {
+		(*h)[0] = c
+		heap.Fix(h, 0)
+	}
+}
+
+// slowest merges the sub-windows, returning the n slowest calls completed within the window, slowest first.
+func (w *slowestWindow) slowest(now time.Time, n int) []CompletedCall {
+	cutoff := now.Add(-w.window)
+
+	var calls []CompletedCall
+	w.ring.each(now, func(h *callHeap) {
+		for _, c := range *h {
+			if !c.end().Before(cutoff) {
+				calls = append(calls, c)
+			}
+		}
+	})
+	sortSlowestFirst(calls)
+	if len(calls) > n {
+		calls = calls[:n]
+	}
+	return calls
+}
+
+var slowestCallsTemplate = template.Must(template.New("slowest").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Slowest RPCs</title></head>
+<body>
+<h1>Slowest RPCs ({{len .}})</h1>
+<table border="1" cellpadding="4">
+<tr><th>Duration</th><th>Side</th><th>Type</th><th>Procedure</th><th>Code</th><th>Start</th><th>Peer</th></tr>
+{{range .}}<tr><td>{{.Duration}}</td><td>{{.Side}}</td><td>{{.Type}}</td><td>{{.Procedure}}</td><td>{{.Code}}</td><td>{{.Start.Format "2006-01-02T15:04:05.000Z07:00"}}</td><td>{{.Peer}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func (s *SlowestCalls) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	calls := s.calls(query.Get("side"), query.Get("procedure"))
+	if calls == nil {
+		calls = []CompletedCall{}
+	}
+	serveDebug(w, r, slowestCallsTemplate, calls)
+}
+
+func sortSlowestFirst(calls []CompletedCall) {
+	sort.Slice(calls, func(i, j int) bool {
+		return calls[i].Duration > calls[j].Duration
+	})
+}
+
+// callHeap is a min-heap of calls by duration, so that the fastest of the calls kept is replaced first.
+type callHeap []CompletedCall
+
+func (h callHeap) Len() int           { return len(h) }
+func (h callHeap) Less(i, j int) bool { return h[i].Duration < h[j].Duration }
+func (h callHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *callHeap) Push(x any) {
+	*h = append(*h, x.(CompletedCall))
+}
+
+func (h *callHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
@@ -0,0 +1,93 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowestCalls(t *testing.T) {
+	slowest := NewSlowestCalls(2, time.Minute)
+	interceptor := NewInterceptor(WithClientMetrics(nil), WithServerMetrics(nil), WithSlowestCalls(slowest))
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetconnect.UnimplementedGreetServiceHandler{}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	for i := 0; i < 3; i++ {
+		createClientAndRequest(t, srv, interceptor)
+	}
+
+	calls := slowest.Server(greetconnect.GreetServiceGreetProcedure)
+	require.Len(t, calls, 2, "only the 2 slowest calls are kept")
+	require.GreaterOrEqual(t, calls[0].Duration, calls[1].Duration, "slowest first")
+	require.Equal(t, connect.CodeUnimplemented.String(), calls[0].Code)
+	require.Equal(t, "server", calls[0].Side)
+
+	calls = slowest.Client(greetconnect.GreetServiceGreetProcedure)
+	require.Len(t, calls, 2, "client-side calls are kept apart from server-side calls")
+	require.Equal(t, "client", calls[0].Side)
+
+	serve := func(query string) []CompletedCall {
+		rec := httptest.NewRecorder()
+		slowest.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/slowest?format=json"+query, nil))
+		var served []CompletedCall
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &served))
+		return served
+	}
+	require.Len(t, serve(""), 4)
+	require.Len(t, serve("&side=client"), 2)
+	served := serve("&side=server&procedure=" + greetconnect.GreetServiceGreetProcedure)
+	require.Len(t, served, 2)
+	require.Equal(t, "server", served[0].Side)
+	require.Empty(t, serve("&procedure=/other.v1.OtherService/Other"))
+}
+
+func TestSlowestCalls_Window(t *testing.T) {
+	now := time.Unix(1000, 0)
+	slowest := NewSlowestCalls(2, time.Minute)
+	slowest.now = func() time.Time { return now }
+
+	finish := func(duration time.Duration) {
+		slowest.callFinished(context.Background(), &call{
+			procedure: "/test.v1.TestService/Test",
+			start:     now.Add(-duration),
+			duration:  duration,
+		})
+	}
+	durations := func() []time.Duration {
+		var ds []time.Duration
+		for _, c := range slowest.Server("/test.v1.TestService/Test") {
+			ds = append(ds, c.Duration)
+		}
+		return ds
+	}
+
+	finish(3 * time.Second)
+	finish(1 * time.Second)
+	finish(2 * time.Second)
+	require.Equal(t, []time.Duration{3 * time.Second, 2 * time.Second}, durations())
+
+	now = now.Add(30 * time.Second)
+	finish(500 * time.Millisecond)
+	require.Equal(t, []time.Duration{3 * time.Second, 2 * time.Second}, durations(), "faster calls of a later sub-window are kept, but only the slowest are returned")
+
+	now = now.Add(45 * time.Second)
+	require.Equal(t, []time.Duration{500 * time.Millisecond}, durations(), "the slowest calls still within the window are kept")
+
+	finish(100 * time.Millisecond)
+	require.Equal(t, []time.Duration{500 * time.Millisecond, 100 * time.Millisecond}, durations())
+
+	now = now.Add(45 * time.Second)
+	require.Equal(t, []time.Duration{100 * time.Millisecond}, durations())
+
+	now = now.Add(2 * time.Minute)
+	require.Empty(t, slowest.All())
+}
@@ -0,0 +1,466 @@
+package connect_go_prometheus
+
+import (
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// DefaultStatsDFlushInterval is the interval at which aggregated metrics are sent.
+	DefaultStatsDFlushInterval = 10 * time.Second
+	// DefaultStatsDMaxPacketSize keeps packets within the MTU of most networks.
+	DefaultStatsDMaxPacketSize = 1432
+	// DefaultStatsDMaxHistogramSamples is the number of observations kept per histogram series between flushes.
+	DefaultStatsDMaxHistogramSamples = 1000
+)
+
+// NewStatsDServerRecorder creates a Recorder sending server-side metrics as DogStatsD packets over UDP to addr.
+// The metric names and tags match those of NewServerMetrics configured with the same MetricsOptions.
+func NewStatsDServerRecorder(addr string, opts ...StatsDOption) (*StatsDRecorder, error) {
+	return newStatsDRecorder(addr, serverMetricsOptions(), opts...)
+}
+
+// NewStatsDClientRecorder creates a Recorder sending client-side metrics as DogStatsD packets over UDP to addr.
+// The metric names and tags match those of NewClientMetrics configured with the same MetricsOptions.
+func NewStatsDClientRecorder(addr string, opts ...StatsDOption) (*StatsDRecorder, error) {
+	return newStatsDRecorder(addr, clientMetricsOptions(), opts...)
+}
+
+func newStatsDRecorder(addr string, defaults *metricsOptions, opts ...StatsDOption) (*StatsDRecorder, error) {
+	options := evaluateStatsDOptions(&statsDOptions{
+		flushInterval:       DefaultStatsDFlushInterval,
+		maxPacketSize:       DefaultStatsDMaxPacketSize,
+		maxHistogramSamples: DefaultStatsDMaxHistogramSamples,
+	}, opts...)
+
+	if options.flushInterval <= 0 {
+		options.flushInterval = DefaultStatsDFlushInterval
+	}
+	if options.maxPacketSize <= 0 {
+		options.maxPacketSize = DefaultStatsDMaxPacketSize
+	}
+	if options.maxHistogramSamples <= 0 {
+		options.maxHistogramSamples = DefaultStatsDMaxHistogramSamples
+	}
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &StatsDRecorder{
+		config:              evaluateMetricsOptions(defaults, options.metricsOptions...),
+		conn:                conn,
+		maxPacketSize:       options.maxPacketSize,
+		maxHistogramSamples: options.maxHistogramSamples,
+		counters:            make(map[statsDKey]float64),
+		gauges:              make(map[statsDKey]float64),
+		histograms:          make(map[statsDKey]*statsDSamples),
+		done:                make(chan struct{}),
+		stopped:             make(chan struct{}),
+	}
+	r.constTags = statsDConstTags(r.config.constLabels)
+
+	go r.run(options.flushInterval)
+	return r, nil
+}
+
+var (
+	_ Recorder             = (*StatsDRecorder)(nil)
+	_ panicRecorder        = (*StatsDRecorder)(nil)
+	_ cancelRecorder       = (*StatsDRecorder)(nil)
+	_ deadlineRecorder     = (*StatsDRecorder)(nil)
+	_ attemptRecorder      = (*StatsDRecorder)(nil)
+	_ logicalCallRecorder  = (*StatsDRecorder)(nil)
+	_ unaryMessageRecorder = (*StatsDRecorder)(nil)
+	_ bytesRecorder        = (*StatsDRecorder)(nil)
+)
+
+// StatsDRecorder is a Recorder sending metrics in the DogStatsD format over UDP. Counters and gauges
+// are aggregated in-process and sent every flush interval. Histogram observations are sampled down to
+// a maximum per series, and sent as multi-value lines with the sample rate applied. Lines are batched
+// into packets of at most the configured maximum size.
+type StatsDRecorder struct {
+	config              *metricsOptions
+	constTags           string
+	conn                net.Conn
+	maxPacketSize       int
+	maxHistogramSamples int
+
+	mu         sync.Mutex
+	counters   map[statsDKey]float64
+	gauges     map[statsDKey]float64
+	histograms map[statsDKey]*statsDSamples
+
+	closeOnce sync.Once
+	done      chan struct{}
+	stopped   chan struct{}
+}
+
+type statsDKey struct {
+	name string
+	tags string
+}
+
+// statsDSamples is a uniform sample of the observations of a histogram series since the last flush.
+type statsDSamples struct {
+	values []float64
+	count  int
+}
+
+// add keeps val with reservoir sampling, so that at most max observations are kept.
+func (s *statsDSamples) add(val float64, max int) {
+	s.count++
+	if len(s.values) < max {
+		s.values = append(s.values, val)
+		return
+	}
+	if i := rand.Intn(s.count); i < max {
+		s.values[i] = val
+	}
+}
+
+// sampleRate is the ratio of the observations which were kept.
+func (s *statsDSamples) sampleRate() float64 {
+	return float64(len(s.values)) / float64(s.count)
+}
+
+func (r *StatsDRecorder) ReportStarted(callType, service, method string) {
+	r.count(r.config.requestStartedName, 1, r.config.typeLabel, labelValue(r.config.typeValues, callType), r.config.serviceLabel, service, r.config.methodLabel, method)
+	if r.config.withInflightMetrics {
+		r.addGauge(r.config.inflightRequestsName, 1, r.config.typeLabel, labelValue(r.config.typeValues, callType), r.config.serviceLabel, service, r.config.methodLabel, method)
+	}
+}
+
+func (r *StatsDRecorder) ReportHandled(callType, service, method, code string) {
+	r.count(r.config.requestHandledName, 1, r.config.typeLabel, labelValue(r.config.typeValues, callType), r.config.serviceLabel, service, r.config.methodLabel, method, r.config.codeLabel, labelValue(r.config.codeValues, code))
+	if r.config.withInflightMetrics {
+		r.addGauge(r.config.inflightRequestsName, -1, r.config.typeLabel, labelValue(r.config.typeValues, callType), r.config.serviceLabel, service, r.config.methodLabel, method)
+	}
+}
+
+func (r *StatsDRecorder) ReportHandledSeconds(callType, service, method, code string, val float64) {
+	if r.config.withHistogram {
+		tags := []string{r.config.typeLabel, labelValue(r.config.typeValues, callType), r.config.serviceLabel, service, r.config.methodLabel, method}
+		if !r.config.secondsWithoutCode {
+			tags = append(tags, r.config.codeLabel, labelValue(r.config.codeValues, code))
+		}
+		r.observe(r.config.requestHandledSecondsName, val, tags...)
+	}
+}
+
+func (r *StatsDRecorder) ReportMsgSent(callType, service, method string) {
+	r.count(r.config.streamMsgSentName, 1, r.config.typeLabel, labelValue(r.config.typeValues, callType), r.config.serviceLabel, service, r.config.methodLabel, method)
+}
+
+func (r *StatsDRecorder) ReportMsgReceived(callType, service, method string) {
+	r.count(r.config.streamMsgReceivedName, 1, r.config.typeLabel, labelValue(r.config.typeValues, callType), r.config.serviceLabel, service, r.config.methodLabel, method)
+}
+
+func (r *StatsDRecorder) reportUnaryMsgSent(callType, service, method string) {
+	if r.config.unaryMessages {
+		r.ReportMsgSent(callType, service, method)
+	}
+}
+
+func (r *StatsDRecorder) reportUnaryMsgReceived(callType, service, method string) {
+	if r.config.unaryMessages {
+		r.ReportMsgReceived(callType, service, method)
+	}
+}
+
+func (r *StatsDRecorder) ReportBytesSent(callType, service, method string, bytes int) {
+	if r.config.withByteMetrics {
+		r.count(r.config.bytesSentName, float64(bytes), r.config.typeLabel, labelValue(r.config.typeValues, callType), r.config.serviceLabel, service, r.config.methodLabel, method)
+	}
+}
+
+func (r *StatsDRecorder) ReportBytesReceived(callType, service, method string, bytes int) {
+	if r.config.withByteMetrics {
+		r.count(r.config.bytesReceivedName, float64(bytes), r.config.typeLabel, labelValue(r.config.typeValues, callType), r.config.serviceLabel, service, r.config.methodLabel, method)
+	}
+}
+
+func (r *StatsDRecorder) ReportPanicked(callType, service, method string) {
+	if r.config.panicsName != "" {
+		r.count(r.config.panicsName, 1, r.config.typeLabel, labelValue(r.config.typeValues, callType), r.config.serviceLabel, service, r.config.methodLabel, method)
+	}
+}
+
+func (r *StatsDRecorder) ReportCanceled(callType, service, method, code, source string) {
+	if r.config.withCancelSourceMetrics && r.config.canceledName != "" {
+		r.count(r.config.canceledName, 1, r.config.typeLabel, labelValue(r.config.typeValues, callType), r.config.serviceLabel, service, r.config.methodLabel, method, r.config.codeLabel, labelValue(r.config.codeValues, code), "cancel_source", source)
+	}
+}
+
+func (r *StatsDRecorder) ReportDeadlineBudget(callType, service, method string, ratio float64) {
+	if r.config.withDeadlineMetrics && r.config.deadlineBudgetName != "" {
+		r.observe(r.config.deadlineBudgetName, ratio, r.config.typeLabel, labelValue(r.config.typeValues, callType), r.config.serviceLabel, service, r.config.methodLabel, method)
+	}
+}
+
+func (r *StatsDRecorder) ReportNoDeadline(callType, service, method string) {
+	if r.config.withDeadlineMetrics && r.config.noDeadlineName != "" {
+		r.count(r.config.noDeadlineName, 1, r.config.typeLabel, labelValue(r.config.typeValues, callType), r.config.serviceLabel, service, r.config.methodLabel, method)
+	}
+}
+
+func (r *StatsDRecorder) ReportAttempt(callType, service, method, attempt, code string) {
+	if r.config.withAttemptMetrics && r.config.attemptsName != "" {
+		r.count(r.config.attemptsName, 1, r.config.typeLabel, labelValue(r.config.typeValues, callType), r.config.serviceLabel, service, r.config.methodLabel, method, "attempt", attempt, r.config.codeLabel, labelValue(r.config.codeValues, code))
+	}
+}
+
+func (r *StatsDRecorder) ReportLogicalCall(callType, service, method, attempts, code string) {
+	if r.config.withAttemptMetrics && r.config.logicalCallsName != "" {
+		r.count(r.config.logicalCallsName, 1, r.config.typeLabel, labelValue(r.config.typeValues, callType), r.config.serviceLabel, service, r.config.methodLabel, method, "attempts", attempts, r.config.codeLabel, labelValue(r.config.codeValues, code))
+	}
+}
+
+func (r *StatsDRecorder) reportsBytes() bool {
+	return r.config.withByteMetrics
+}
+
+// Flush sends all metrics aggregated since the previous flush.
+func (r *StatsDRecorder) Flush() error {
+	r.mu.Lock()
+	lines := make([]string, 0, len(r.counters)+len(r.gauges)+len(r.histograms))
+	for key, val := range r.counters {
+		lines = append(lines, statsDLine(key, formatStatsDValue(val), "c"))
+	}
+	for key, val := range r.gauges {
+		lines = append(lines, statsDLine(key, formatStatsDValue(val), "g"))
+	}
+	for key, samples := range r.histograms {
+		lines = append(lines, r.histogramLines(key, samples)...)
+	}
+	// Counters and histograms are deltas, gauges keep their value across flushes.
+	r.counters = make(map[statsDKey]float64)
+	r.histograms = make(map[statsDKey]*statsDSamples)
+	r.mu.Unlock()
+
+	return r.send(lines)
+}
+
+// Close flushes any remaining metrics, and stops the recorder.
+func (r *StatsDRecorder) Close() error {
+	var err error
+	r.closeOnce.Do(func() {
+		close(r.done)
+		<-r.stopped
+		err = r.Flush()
+		if closeErr := r.conn.Close(); err == nil {
+			err = closeErr
+		}
+	})
+	return err
+}
+
+func (r *StatsDRecorder) run(interval time.Duration) {
+	defer close(r.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// Errors sending over UDP are transient, the next flush carries on.
+			_ = r.Flush()
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// send batches lines into packets of at most maxPacketSize bytes.
+func (r *StatsDRecorder) send(lines []string) error {
+	var packet strings.Builder
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > r.maxPacketSize {
+			if _, err := r.conn.Write([]byte(packet.String())); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if packet.Len() > 0 {
+		if _, err := r.conn.Write([]byte(packet.String())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *StatsDRecorder) count(name string, val float64, labels ...string) {
+	key := r.key(name, labels)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counters[key] += val
+}
+
+func (r *StatsDRecorder) addGauge(name string, val float64, labels ...string) {
+	key := r.key(name, labels)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gauges[key] += val
+}
+
+func (r *StatsDRecorder) observe(name string, val float64, labels ...string) {
+	key := r.key(name, labels)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	samples, ok := r.histograms[key]
+	if !ok {
+		samples = &statsDSamples{}
+		r.histograms[key] = samples
+	}
+	samples.add(val, r.maxHistogramSamples)
+}
+
+// histogramLines returns the multi-value lines of the samples of a histogram series, each within the
+// maximum packet size.
+func (r *StatsDRecorder) histogramLines(key statsDKey, samples *statsDSamples) []string {
+	metricType := "h"
+	if rate := samples.sampleRate(); rate < 1 {
+		metricType += "|@" + formatStatsDValue(rate)
+	}
+	// The name, type and tags are repeated on every line, leaving the rest of the packet for the values.
+	room := r.maxPacketSize - len(statsDLine(key, "", metricType))
+
+	var lines []string
+	var values strings.Builder
+	for _, val := range samples.values {
+		v := formatStatsDValue(val)
+		if values.Len() > 0 && values.Len()+1+len(v) > room {
+			lines = append(lines, statsDLine(key, values.String(), metricType))
+			values.Reset()
+		}
+		if values.Len() > 0 {
+			values.WriteByte(':')
+		}
+		values.WriteString(v)
+	}
+	if values.Len() > 0 {
+		lines = append(lines, statsDLine(key, values.String(), metricType))
+	}
+	return lines
+}
+
+// key builds the fully qualified name, as Prometheus would, and the tags from alternating label names and values.
+func (r *StatsDRecorder) key(name string, labels []string) statsDKey {
+	var tags strings.Builder
+	for i := 0; i+1 < len(labels); i += 2 {
+		if tags.Len() > 0 {
+			tags.WriteByte(',')
+		}
+		tags.WriteString(labels[i])
+		tags.WriteByte(':')
+		tags.WriteString(sanitizeStatsDTag(labels[i+1]))
+	}
+	if r.constTags != "" {
+		if tags.Len() > 0 {
+			tags.WriteByte(',')
+		}
+		tags.WriteString(r.constTags)
+	}
+	return statsDKey{
+		name: prom.BuildFQName(r.config.namespace, r.config.subsystem, name),
+		tags: tags.String(),
+	}
+}
+
+func statsDConstTags(labels prom.Labels) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tags := make([]string, 0, len(names))
+	for _, name := range names {
+		tags = append(tags, name+":"+sanitizeStatsDTag(labels[name]))
+	}
+	return strings.Join(tags, ",")
+}
+
+// sanitizeStatsDTag replaces the characters delimiting DogStatsD tags and lines.
+func sanitizeStatsDTag(value string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ',', '|', '#', '\n':
+			return '_'
+		}
+		return r
+	}, value)
+}
+
+func statsDLine(key statsDKey, value, metricType string) string {
+	line := key.name + ":" + value + "|" + metricType
+	if key.tags != "" {
+		line += "|#" + key.tags
+	}
+	return line
+}
+
+func formatStatsDValue(val float64) string {
+	return strconv.FormatFloat(val, 'f', -1, 64)
+}
+
+type statsDOptions struct {
+	metricsOptions      []MetricsOption
+	flushInterval       time.Duration
+	maxPacketSize       int
+	maxHistogramSamples int
+}
+
+type StatsDOption func(*statsDOptions)
+
+// WithStatsDMetricsOptions configures the metrics sent, with the same options as NewServerMetrics and
+// NewClientMetrics. Options only relevant to Prometheus, such as histogram buckets, are ignored.
+func WithStatsDMetricsOptions(opts ...MetricsOption) StatsDOption {
+	return func(o *statsDOptions) {
+		o.metricsOptions = append(o.metricsOptions, opts...)
+	}
+}
+
+// WithStatsDFlushInterval sets how often aggregated metrics are sent, defaults to DefaultStatsDFlushInterval.
+// Non-positive intervals use the default.
+func WithStatsDFlushInterval(interval time.Duration) StatsDOption {
+	return func(o *statsDOptions) {
+		o.flushInterval = interval
+	}
+}
+
+// WithStatsDMaxPacketSize sets the maximum size of a packet, defaults to DefaultStatsDMaxPacketSize.
+// Non-positive sizes use the default.
+func WithStatsDMaxPacketSize(size int) StatsDOption {
+	return func(o *statsDOptions) {
+		o.maxPacketSize = size
+	}
+}
+
+// WithStatsDMaxHistogramSamples sets the number of observations kept per histogram series between flushes,
+// defaults to DefaultStatsDMaxHistogramSamples. Beyond it, observations are sampled uniformly and sent
+// with their sample rate. Non-positive values use the default.
+func WithStatsDMaxHistogramSamples(n int) StatsDOption {
+	return func(o *statsDOptions) {
+		o.maxHistogramSamples = n
+	}
+}
+
+func evaluateStatsDOptions(defaults *statsDOptions, opts ...StatsDOption) *statsDOptions {
+	for _, opt := range opts {
+		opt(defaults)
+	}
+	return defaults
+}
@@ -0,0 +1,175 @@
+package connect_go_prometheus
+
+import (
+	"net"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func listenStatsD(t *testing.T) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func readStatsDLines(t *testing.T, conn *net.UDPConn, count int) []string {
+	t.Helper()
+	var lines []string
+	buf := make([]byte, 65536)
+	for len(lines) < count {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		n, err := conn.Read(buf)
+		require.NoError(t, err)
+		lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func TestStatsDRecorder(t *testing.T) {
+	listener := listenStatsD(t)
+
+	recorder, err := NewStatsDServerRecorder(listener.LocalAddr().String(),
+		WithStatsDFlushInterval(time.Hour),
+		WithStatsDMetricsOptions(
+			WithHistogram(true),
+			WithNamespace("namespace"),
+			WithConstLabels(prom.Labels{"component": "foo"}),
+		),
+	)
+	require.NoError(t, err)
+	defer recorder.Close()
+
+	interceptor := NewInterceptor(WithServerRecorder(recorder), WithClientRecorder(nil))
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetconnect.UnimplementedGreetServiceHandler{}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	createClientAndRequest(t, srv, interceptor)
+	createClientAndRequest(t, srv, interceptor)
+	require.NoError(t, recorder.Flush())
+
+	lines := readStatsDLines(t, listener, 3)
+	require.Len(t, lines, 3)
+	require.Equal(t, "namespace_connect_server_handled_seconds", lines[0][:strings.Index(lines[0], ":")])
+	require.True(t, strings.HasSuffix(lines[0], "|h|#type:unary,service:greet.v1.GreetService,method:Greet,code:unimplemented,component:foo"), lines[0])
+	require.Len(t, strings.Split(lines[0][:strings.Index(lines[0], "|")], ":"), 3, "both observations are sent on one line")
+	require.Equal(t, []string{
+		"namespace_connect_server_handled_total:2|c|#type:unary,service:greet.v1.GreetService,method:Greet,code:unimplemented,component:foo",
+		"namespace_connect_server_started_total:2|c|#type:unary,service:greet.v1.GreetService,method:Greet,component:foo",
+	}, lines[1:])
+}
+
+func TestStatsDRecorder_Batching(t *testing.T) {
+	listener := listenStatsD(t)
+
+	recorder, err := NewStatsDClientRecorder(listener.LocalAddr().String(),
+		WithStatsDFlushInterval(time.Hour),
+		WithStatsDMaxPacketSize(100),
+		WithStatsDMetricsOptions(WithInflightMetrics(true)),
+	)
+	require.NoError(t, err)
+
+	recorder.ReportStarted("unary", "svc", "A")
+	recorder.ReportStarted("unary", "svc", "B")
+	recorder.ReportHandled("unary", "svc", "B", CodeOk)
+	require.NoError(t, recorder.Close())
+
+	buf := make([]byte, 65536)
+	var lines []string
+	for len(lines) < 5 {
+		require.NoError(t, listener.SetReadDeadline(time.Now().Add(5*time.Second)))
+		n, err := listener.Read(buf)
+		require.NoError(t, err)
+		require.LessOrEqual(t, n, 100)
+		lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+	}
+	sort.Strings(lines)
+	require.Equal(t, []string{
+		"connect_client_handled_total:1|c|#type:unary,service:svc,method:B,code:ok",
+		"connect_client_inflight_requests:0|g|#type:unary,service:svc,method:B",
+		"connect_client_inflight_requests:1|g|#type:unary,service:svc,method:A",
+		"connect_client_started_total:1|c|#type:unary,service:svc,method:A",
+		"connect_client_started_total:1|c|#type:unary,service:svc,method:B",
+	}, lines)
+}
+
+func TestStatsDRecorder_HistogramSamples(t *testing.T) {
+	listener := listenStatsD(t)
+
+	recorder, err := NewStatsDServerRecorder(listener.LocalAddr().String(),
+		WithStatsDFlushInterval(time.Hour),
+		WithStatsDMaxPacketSize(120),
+		WithStatsDMaxHistogramSamples(40),
+		WithStatsDMetricsOptions(WithHistogram(true)),
+	)
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		recorder.ReportHandledSeconds("unary", "svc", "A", CodeOk, 0.25)
+	}
+	require.NoError(t, recorder.Close())
+
+	var values, lines int
+	for values < 40 {
+		for _, line := range readStatsDLines(t, listener, 1) {
+			require.LessOrEqual(t, len(line), 120)
+			require.True(t, strings.HasSuffix(line, "|h|@0.4|#type:unary,service:svc,method:A,code:ok"), line)
+			values += len(strings.Split(line[:strings.Index(line, "|")], ":")) - 1
+			lines++
+		}
+	}
+	require.Equal(t, 40, values, "observations beyond the maximum are sampled")
+	require.Greater(t, lines, 1, "values are split across lines within the packet size")
+}
+
+func TestStatsDRecorder_NonPositiveOptions(t *testing.T) {
+	listener := listenStatsD(t)
+
+	recorder, err := NewStatsDServerRecorder(listener.LocalAddr().String(),
+		WithStatsDFlushInterval(0),
+		WithStatsDMaxPacketSize(-1),
+		WithStatsDMaxHistogramSamples(0),
+	)
+	require.NoError(t, err, "must not panic on a non-positive flush interval")
+	require.Equal(t, DefaultStatsDMaxPacketSize, recorder.maxPacketSize)
+	require.Equal(t, DefaultStatsDMaxHistogramSamples, recorder.maxHistogramSamples)
+
+	recorder.ReportStarted("unary", "svc", "A")
+	require.NoError(t, recorder.Close())
+	require.Equal(t, []string{
+		"connect_server_started_total:1|c|#type:unary,service:svc,method:A",
+	}, readStatsDLines(t, listener, 1))
+}
+
+func TestStatsDRecorder_GRPCPrometheusNames(t *testing.T) {
+	listener := listenStatsD(t)
+
+	recorder, err := NewStatsDServerRecorder(listener.LocalAddr().String(),
+		WithStatsDFlushInterval(time.Hour),
+		WithStatsDMetricsOptions(WithGRPCPrometheusNames(), WithHistogram(true)),
+	)
+	require.NoError(t, err)
+
+	recorder.ReportHandled("unary", "svc", "A", CodeOk)
+	recorder.ReportHandledSeconds("unary", "svc", "A", CodeOk, 0.5)
+	reportUnaryMsgReceived(recorder, "unary", "svc", "A")
+	require.NoError(t, recorder.Close())
+
+	require.ElementsMatch(t, []string{
+		"grpc_server_handled_total:1|c|#grpc_type:unary,grpc_service:svc,grpc_method:A,grpc_code:OK",
+		"grpc_server_msg_received_total:1|c|#grpc_type:unary,grpc_service:svc,grpc_method:A",
+		"grpc_server_handling_seconds:0.5|h|#grpc_type:unary,grpc_service:svc,grpc_method:A",
+	}, readStatsDLines(t, listener, 3))
+}
@@ -0,0 +1,125 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewTracker creates a tracker of the RPCs currently in flight. Use it with WithTracker, and serve it
+// on a debug endpoint to inspect the active calls as HTML, or as JSON with ?format=json.
+func NewTracker() *Tracker {
+	return &Tracker{
+		active: make(map[*call]struct{}),
+	}
+}
+
+var (
+	_ callObserver = (*Tracker)(nil)
+	_ http.Handler = (*Tracker)(nil)
+)
+
+type Tracker struct {
+	mu     sync.Mutex
+	active map[*call]struct{}
+}
+
+// ActiveCall is a snapshot of a RPC in flight.
+type ActiveCall struct {
+	Side        string        `json:"side"`
+	Type        string        `json:"type"`
+	Service     string        `json:"service"`
+	Method      string        `json:"method"`
+	Peer        string        `json:"peer"`
+	Start       time.Time     `json:"start"`
+	Elapsed     time.Duration `json:"elapsed"`
+	MsgSent     int64         `json:"msg_sent"`
+	MsgReceived int64         `json:"msg_received"`
+}
+
+// ActiveCalls returns the calls currently in flight, longest running first.
+func (t *Tracker) ActiveCalls() []ActiveCall {
+	now := time.Now()
+
+	t.mu.Lock()
+	calls := make([]ActiveCall, 0, len(t.active))
+	for c := range t.active {
+		calls = append(calls, ActiveCall{
+			Side:        sideOf(c.isClient),
+			Type:        c.callType,
+			Service:     c.service,
+			Method:      c.method,
+			Peer:        c.peer,
+			Start:       c.start,
+			Elapsed:     now.Sub(c.start),
+			MsgSent:     c.msgSent.Load(),
+			MsgReceived: c.msgReceived.Load(),
+		})
+	}
+	t.mu.Unlock()
+
+	sort.Slice(calls, func(i, j int) bool {
+		return calls[i].Start.Before(calls[j].Start)
+	})
+	return calls
+}
+
+func (t *Tracker) callStarted(_ context.Context, c *call) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.active[c] = struct{}{}
+}
+
+func (t *Tracker) callFinished(_ context.Context, c *call) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.active, c)
+}
+
+var activeCallsTemplate = template.Must(template.New("active").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Active RPCs</title></head>
+<body>
+<h1>Active RPCs ({{len .}})</h1>
+<table border="1" cellpadding="4">
+<tr><th>Side</th><th>Type</th><th>Service</th><th>Method</th><th>Peer</th><th>Start</th><th>Elapsed</th><th>Sent</th><th>Received</th></tr>
+{{range .}}<tr><td>{{.Side}}</td><td>{{.Type}}</td><td>{{.Service}}</td><td>{{.Method}}</td><td>{{.Peer}}</td><td>{{.Start.Format "2006-01-02T15:04:05.000Z07:00"}}</td><td>{{.Elapsed}}</td><td>{{.MsgSent}}</td><td>{{.MsgReceived}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func (t *Tracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveDebug(w, r, activeCallsTemplate, t.ActiveCalls())
+}
+
+func sideOf(isClient bool) string {
+	if isClient {
+		return "client"
+	}
+	return "server"
+}
+
+// serveDebug writes data as JSON when requested with ?format=json or an Accept header preferring JSON,
+// and as HTML rendered with tmpl otherwise.
+func serveDebug(w http.ResponseWriter, r *http.Request, tmpl *template.Template, data any) {
+	if r.URL.Query().Get("format") == "json" || strings.HasPrefix(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
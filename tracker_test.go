@@ -0,0 +1,78 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	"github.com/stretchr/testify/require"
+)
+
+type blockingGreetServiceHandler struct {
+	greetconnect.UnimplementedGreetServiceHandler
+	started chan struct{}
+	release chan struct{}
+}
+
+func (h blockingGreetServiceHandler) ServerStreamGreet(_ context.Context, _ *connect.Request[greet.GreetRequest], stream *connect.ServerStream[greet.GreetResponse]) error {
+	if err := stream.Send(&greet.GreetResponse{Greeting: "hello"}); err != nil {
+		return err
+	}
+	close(h.started)
+	<-h.release
+	return nil
+}
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker()
+	interceptor := NewInterceptor(WithClientMetrics(nil), WithServerMetrics(nil), WithTracker(tracker))
+
+	handler := blockingGreetServiceHandler{started: make(chan struct{}), release: make(chan struct{})}
+	_, h := greetconnect.NewGreetServiceHandler(handler, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL)
+		stream, err := client.ServerStreamGreet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "eliza"}))
+		if err == nil {
+			for stream.Receive() {
+			}
+			_ = stream.Close()
+		}
+	}()
+	<-handler.started
+
+	calls := tracker.ActiveCalls()
+	require.Len(t, calls, 1)
+	require.Equal(t, "server", calls[0].Side)
+	require.Equal(t, "server_stream", calls[0].Type)
+	require.Equal(t, greetconnect.GreetServiceName, calls[0].Service)
+	require.Equal(t, "ServerStreamGreet", calls[0].Method)
+	require.EqualValues(t, 1, calls[0].MsgSent)
+	require.EqualValues(t, 1, calls[0].MsgReceived)
+
+	rec := httptest.NewRecorder()
+	tracker.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/rpcz?format=json", nil))
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var served []ActiveCall
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &served))
+	require.Len(t, served, 1)
+	require.Equal(t, "ServerStreamGreet", served[0].Method)
+
+	rec = httptest.NewRecorder()
+	tracker.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/rpcz", nil))
+	require.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	require.Contains(t, rec.Body.String(), "<td>ServerStreamGreet</td>")
+
+	close(handler.release)
+	<-done
+	require.Empty(t, tracker.ActiveCalls())
+}
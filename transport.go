@@ -0,0 +1,165 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+type callTypeKey struct{}
+
+// withCallType stores the call type of a client-side RPC in the context, so that the Transport can
+// report with the same labels as the interceptor.
+func withCallType(ctx context.Context, callType string) context.Context {
+	return context.WithValue(ctx, callTypeKey{}, callType)
+}
+
+func callTypeOf(ctx context.Context) string {
+	if callType, ok := ctx.Value(callTypeKey{}).(string); ok {
+		return callType
+	}
+	return "unknown"
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+// Transport is a http.RoundTripper recording the time spent in DNS lookups, connection establishment,
+// TLS handshakes and waiting for the first response byte of each RPC, as well as whether the RPC
+// reused an existing connection to the peer. The time to the first response byte is measured once a
+// connection is obtained, so that it excludes the DNS, dial and TLS handshake time. Use it in the http.Client passed
+// to a generated Connect client, together with client metrics created WithTransportMetrics(true).
+//
+// The call type is only known for RPCs made through a client using the Interceptor, otherwise it is
+// reported as unknown.
+type Transport struct {
+	metrics *Metrics
+	base    http.RoundTripper
+}
+
+// NewTransport wraps base to record transport metrics against m. When base is nil, http.DefaultTransport is used.
+func NewTransport(m *Metrics, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{
+		metrics: m,
+		base:    base,
+	}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.metrics == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	timings := &transportTimings{}
+	ctx := httptrace.WithClientTrace(req.Context(), timings.clientTrace())
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+
+	callType := callTypeOf(req.Context())
+	service, method := procedureFromPath(req.URL.Path)
+	timings.report(t.metrics, callType, service, method)
+	timings.reportConn(t.metrics, service, method, req.URL.Host)
+	return resp, err
+}
+
+// transportTimings collects the timings of a single round trip. The httptrace hooks may be called
+// concurrently, for example when dialing multiple addresses.
+type transportTimings struct {
+	mu sync.Mutex
+
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	gotConnAt, firstByte      time.Time
+
+	gotConn  bool
+	connInfo httptrace.GotConnInfo
+}
+
+func (tt *transportTimings) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			tt.record(&tt.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			tt.record(&tt.dnsDone)
+		},
+		ConnectStart: func(string, string) {
+			tt.mu.Lock()
+			defer tt.mu.Unlock()
+			if tt.connectStart.IsZero() {
+				tt.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				tt.record(&tt.connectDone)
+			}
+		},
+		TLSHandshakeStart: func() {
+			tt.record(&tt.tlsStart)
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				tt.record(&tt.tlsDone)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			tt.mu.Lock()
+			defer tt.mu.Unlock()
+			tt.gotConn = true
+			tt.gotConnAt = time.Now()
+			tt.connInfo = info
+		},
+		GotFirstResponseByte: func() {
+			tt.record(&tt.firstByte)
+		},
+	}
+}
+
+func (tt *transportTimings) record(t *time.Time) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	*t = time.Now()
+}
+
+func (tt *transportTimings) report(m *Metrics, callType, service, method string) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	callType = m.typeValue(callType)
+	observeBetween(m.dnsSeconds, tt.dnsStart, tt.dnsDone, callType, service, method)
+	observeBetween(m.dialSeconds, tt.connectStart, tt.connectDone, callType, service, method)
+	observeBetween(m.tlsHandshakeSeconds, tt.tlsStart, tt.tlsDone, callType, service, method)
+	observeBetween(m.firstByteSeconds, tt.gotConnAt, tt.firstByte, callType, service, method)
+}
+
+func (tt *transportTimings) reportConn(m *Metrics, service, method, peer string) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	if !tt.gotConn {
+		return
+	}
+	if m.conns != nil {
+		m.conns.WithLabelValues(service, method, peer, strconv.FormatBool(tt.connInfo.Reused), strconv.FormatBool(tt.connInfo.WasIdle)).Inc()
+	}
+	if m.connIdleSeconds != nil && tt.connInfo.WasIdle {
+		m.connIdleSeconds.WithLabelValues(service, method, peer).Add(tt.connInfo.IdleTime.Seconds())
+	}
+}
+
+// observeBetween observes the duration between start and end, if both occurred during the round trip.
+func observeBetween(h *prom.HistogramVec, start, end time.Time, labels ...string) {
+	if h == nil || start.IsZero() || end.IsZero() {
+		return
+	}
+	h.WithLabelValues(labels...).Observe(end.Sub(start).Seconds())
+}
@@ -0,0 +1,94 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransport(t *testing.T) {
+	clientMetrics := NewClientMetrics(WithTransportMetrics(true))
+	interceptor := NewInterceptor(WithClientMetrics(clientMetrics), WithServerMetrics(nil))
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetconnect.UnimplementedGreetServiceHandler{})
+	srv := httptest.NewTLSServer(handler)
+	defer srv.Close()
+
+	httpClient := &http.Client{Transport: NewTransport(clientMetrics, srv.Client().Transport)}
+	client := greetconnect.NewGreetServiceClient(httpClient, srv.URL, connect.WithInterceptors(interceptor))
+	for i := 0; i < 2; i++ {
+		_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "eliza"}))
+		require.Equal(t, connect.CodeUnimplemented, connect.CodeOf(err))
+	}
+
+	require.Equal(t, 1, testutil.CollectAndCount(clientMetrics.dialSeconds), "only the first RPC dials a new connection")
+	require.Equal(t, 1, testutil.CollectAndCount(clientMetrics.tlsHandshakeSeconds))
+	require.Equal(t, 1, testutil.CollectAndCount(clientMetrics.firstByteSeconds))
+	require.Equal(t, 0, testutil.CollectAndCount(clientMetrics.dnsSeconds))
+
+	peer := strings.TrimPrefix(srv.URL, "https://")
+	require.EqualValues(t, 1, testutil.ToFloat64(clientMetrics.conns.WithLabelValues(greetconnect.GreetServiceName, "Greet", peer, "false", "false")))
+	require.EqualValues(t, 1, testutil.ToFloat64(clientMetrics.conns.WithLabelValues(greetconnect.GreetServiceName, "Greet", peer, "true", "true")))
+	require.Equal(t, 1, testutil.CollectAndCount(clientMetrics.connIdleSeconds))
+
+	require.True(t, clientMetrics.firstByteSeconds.DeleteLabelValues("unary", greetconnect.GreetServiceName, "Greet"), "must report with the labels of the interceptor")
+}
+
+func TestTransportTimings(t *testing.T) {
+	clientMetrics := NewClientMetrics(WithTransportMetrics(true), WithHistogramBuckets([]float64{1}))
+
+	start := time.Unix(1000, 0)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+	timings := &transportTimings{
+		dnsStart:     start,
+		dnsDone:      at(time.Second),
+		connectStart: at(time.Second),
+		connectDone:  at(2 * time.Second),
+		tlsStart:     at(2 * time.Second),
+		tlsDone:      at(3 * time.Second),
+		gotConnAt:    at(3 * time.Second),
+		firstByte:    at(3500 * time.Millisecond),
+	}
+	timings.report(clientMetrics, "unary", greetconnect.GreetServiceName, "Greet")
+
+	err := testutil.CollectAndCompare(clientMetrics.firstByteSeconds, strings.NewReader(`
+			# HELP connect_client_first_byte_seconds Histogram of time from obtaining a connection to the first response byte for RPCs client-side
+			# TYPE connect_client_first_byte_seconds histogram
+			connect_client_first_byte_seconds_bucket{method="Greet",service="greet.v1.GreetService",type="unary",le="1"} 1
+			connect_client_first_byte_seconds_bucket{method="Greet",service="greet.v1.GreetService",type="unary",le="+Inf"} 1
+			connect_client_first_byte_seconds_sum{method="Greet",service="greet.v1.GreetService",type="unary"} 0.5
+			connect_client_first_byte_seconds_count{method="Greet",service="greet.v1.GreetService",type="unary"} 1
+		`))
+	require.NoError(t, err, "the DNS, dial and TLS handshake time is excluded")
+}
+
+func TestTransportTimings_GRPCPrometheusNames(t *testing.T) {
+	clientMetrics := NewClientMetrics(WithTransportMetrics(true), WithGRPCPrometheusNames())
+
+	start := time.Unix(1000, 0)
+	timings := &transportTimings{
+		dnsStart:     start,
+		dnsDone:      start.Add(time.Second),
+		connectStart: start.Add(time.Second),
+		connectDone:  start.Add(2 * time.Second),
+		tlsStart:     start.Add(2 * time.Second),
+		tlsDone:      start.Add(3 * time.Second),
+		gotConnAt:    start.Add(3 * time.Second),
+		firstByte:    start.Add(4 * time.Second),
+	}
+	timings.report(clientMetrics, "bidi", greetconnect.GreetServiceName, "Greet")
+
+	for _, h := range []*prom.HistogramVec{clientMetrics.dnsSeconds, clientMetrics.dialSeconds, clientMetrics.tlsHandshakeSeconds, clientMetrics.firstByteSeconds} {
+		require.True(t, h.DeleteLabelValues("bidi_stream", greetconnect.GreetServiceName, "Greet"), "must report the go-grpc-prometheus type")
+	}
+}
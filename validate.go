@@ -0,0 +1,153 @@
+package connect_go_prometheus
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cockroachdb/errors"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil/promlint"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+)
+
+// validNamePart matches a namespace or subsystem. Colons are valid in metric names, but reserved for recording rules.
+var validNamePart = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+var metricTypes = map[string]dto.MetricType{
+	"counter":   dto.MetricType_COUNTER,
+	"gauge":     dto.MetricType_GAUGE,
+	"histogram": dto.MetricType_HISTOGRAM,
+}
+
+// validateMetricsOptions returns the problems making the metrics built from config invalid, which would fail
+// their registration, and their violations of the Prometheus naming conventions.
+func validateMetricsOptions(config *metricsOptions, build func(*metricsOptions) *Metrics) (invalid, violations []string) {
+	for _, part := range []struct{ kind, value string }{
+		{kind: "namespace", value: config.namespace},
+		{kind: "subsystem", value: config.subsystem},
+	} {
+		if part.value != "" && !validNamePart.MatchString(part.value) {
+			invalid = append(invalid, fmt.Sprintf("%s %q must only contain letters, digits and underscores, and not start with a digit", part.kind, part.value))
+		}
+	}
+
+	var invalidLabels []string
+	seenLabels := make(map[string]bool)
+	for _, name := range []string{config.typeLabel, config.serviceLabel, config.methodLabel, config.codeLabel} {
+		switch {
+		case !model.LabelName(name).IsValid():
+			invalidLabels = append(invalidLabels, fmt.Sprintf("label %q is not a valid label name", name))
+		case strings.HasPrefix(name, model.ReservedLabelPrefix):
+			invalidLabels = append(invalidLabels, fmt.Sprintf("label %q uses the %q prefix reserved for internal use", name, model.ReservedLabelPrefix))
+		case seenLabels[name]:
+			invalidLabels = append(invalidLabels, fmt.Sprintf("label %q is used for more than one label", name))
+		}
+		seenLabels[name] = true
+	}
+	if len(invalidLabels) > 0 {
+		// Every metric has these labels, checking the metrics would only repeat these problems.
+		return append(invalid, invalidLabels...), nil
+	}
+
+	constLabels := make([]string, 0, len(config.constLabels))
+	for name := range config.constLabels {
+		constLabels = append(constLabels, name)
+	}
+	sort.Strings(constLabels)
+	for _, name := range constLabels {
+		switch {
+		case !model.LabelName(name).IsValid():
+			invalid = append(invalid, fmt.Sprintf("const label %q is not a valid label name", name))
+		case strings.HasPrefix(name, model.ReservedLabelPrefix):
+			invalid = append(invalid, fmt.Sprintf("const label %q uses the %q prefix reserved for internal use", name, model.ReservedLabelPrefix))
+		}
+		if !utf8.ValidString(config.constLabels[name]) {
+			invalid = append(invalid, fmt.Sprintf("value of const label %q is not valid UTF-8", name))
+		}
+	}
+
+	for _, slo := range config.slos {
+		if config.isClient {
+			// SLOs have no effect on client metrics.
+			break
+		}
+		if problem := slo.invalid(); problem != "" {
+			invalid = append(invalid, problem)
+		}
+	}
+
+	seen := make(map[string]bool)
+	var families []*dto.MetricFamily
+	for _, family := range Catalogue(build(config)) {
+		if !model.IsValidMetricName(model.LabelValue(family.Name)) {
+			invalid = append(invalid, fmt.Sprintf("metric name %q is not valid", family.Name))
+		}
+		if seen[family.Name] {
+			invalid = append(invalid, fmt.Sprintf("metric name %q is used by more than one metric", family.Name))
+		}
+		seen[family.Name] = true
+
+		labels := make(map[string]bool, len(family.Labels))
+		for _, label := range family.Labels {
+			if labels[label] {
+				invalid = append(invalid, fmt.Sprintf("label %q is used more than once by %s", label, family.Name))
+			}
+			labels[label] = true
+		}
+
+		reserved := family.Labels
+		if family.Type == "histogram" {
+			// Copy, so that appending never writes into the backing array of the family's labels.
+			reserved = append(slices.Clone(family.Labels), "le")
+		}
+		for _, name := range constLabels {
+			for _, label := range reserved {
+				if name == label {
+					invalid = append(invalid, fmt.Sprintf("const label %q clashes with the %q label of %s", name, label, family.Name))
+				}
+			}
+		}
+
+		families = append(families, lintFamily(family, config.constLabels))
+	}
+
+	problems, err := promlint.NewWithMetricFamilies(families).Lint()
+	if err != nil {
+		violations = append(violations, err.Error())
+	}
+	for _, problem := range problems {
+		violations = append(violations, fmt.Sprintf("%s: %s", problem.Metric, problem.Text))
+	}
+	return invalid, violations
+}
+
+// lintFamily returns family as a metric family with a single series, so that promlint checks its labels.
+func lintFamily(family MetricFamily, constLabels prom.Labels) *dto.MetricFamily {
+	name, help, typ := family.Name, family.Help, metricTypes[family.Type]
+
+	var labels []*dto.LabelPair
+	for _, label := range family.Labels {
+		label := label
+		labels = append(labels, &dto.LabelPair{Name: &label})
+	}
+	for label := range constLabels {
+		label := label
+		labels = append(labels, &dto.LabelPair{Name: &label})
+	}
+
+	return &dto.MetricFamily{
+		Name:   &name,
+		Help:   &help,
+		Type:   &typ,
+		Metric: []*dto.Metric{{Label: labels}},
+	}
+}
+
+func metricsOptionsError(problems []string) error {
+	return errors.Newf("invalid metrics options: %s", strings.Join(problems, "; "))
+}
@@ -0,0 +1,91 @@
+package connect_go_prometheus
+
+import (
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTryNewMetrics_Defaults(t *testing.T) {
+	_, err := TryNewServerMetrics(readmeServerMetricsOptions()...)
+	require.NoError(t, err, "the default names follow the conventions")
+	_, err = TryNewClientMetrics(readmeClientMetricsOptions()...)
+	require.NoError(t, err)
+
+	_, err = TryNewServerMetrics(WithNamespace("acme"), WithSubsystem("api"), WithConstLabels(prom.Labels{"component": "greeter"}))
+	require.NoError(t, err)
+}
+
+func TestTryNewMetrics_Invalid(t *testing.T) {
+	for name, tc := range map[string]struct {
+		opts []MetricsOption
+		err  string
+	}{
+		"namespace with a dash": {
+			opts: []MetricsOption{WithNamespace("my-app")},
+			err:  `namespace "my-app" must only contain letters, digits and underscores, and not start with a digit`,
+		},
+		"subsystem with a colon": {
+			opts: []MetricsOption{WithSubsystem("api:v1")},
+			err:  `subsystem "api:v1" must only contain letters, digits and underscores`,
+		},
+		"invalid const label": {
+			opts: []MetricsOption{WithConstLabels(prom.Labels{"my-label": "x"})},
+			err:  `const label "my-label" is not a valid label name`,
+		},
+		"reserved const label prefix": {
+			opts: []MetricsOption{WithConstLabels(prom.Labels{"__name": "x"})},
+			err:  `const label "__name" uses the "__" prefix reserved for internal use`,
+		},
+		"const label clashing with a label": {
+			opts: []MetricsOption{WithConstLabels(prom.Labels{"service": "x"})},
+			err:  `const label "service" clashes with the "service" label of connect_server_started_total`,
+		},
+		"invalid label name": {
+			opts: []MetricsOption{WithLabelNames(LabelNames{Service: "rpc-service"})},
+			err:  `label "rpc-service" is not a valid label name`,
+		},
+		"reserved label prefix": {
+			opts: []MetricsOption{WithLabelNames(LabelNames{Code: "__code"})},
+			err:  `label "__code" uses the "__" prefix reserved for internal use`,
+		},
+		"label used twice": {
+			opts: []MetricsOption{WithLabelNames(LabelNames{Method: "service"})},
+			err:  `label "service" is used for more than one label`,
+		},
+		"label clashing with an extra label": {
+			opts: []MetricsOption{WithCancelSourceMetrics(true), WithLabelNames(LabelNames{Code: "cancel_source"})},
+			err:  `label "cancel_source" is used more than once by connect_server_canceled_total`,
+		},
+		"metric name used twice": {
+			opts: []MetricsOption{WithStartedName("handled_total"), WithHandledName("handled_total")},
+			err:  `metric name "handled_total" is used by more than one metric`,
+		},
+		"const label clashing with histogram buckets": {
+			opts: []MetricsOption{WithHistogram(true), WithConstLabels(prom.Labels{"le": "x"})},
+			err:  `const label "le" clashes with the "le" label of connect_server_handled_seconds`,
+		},
+		"SLO availability out of range": {
+			opts: []MetricsOption{WithSLOs(SLO{Procedure: "/greet.v1.GreetService/Greet", Availability: 99.9})},
+			err:  `availability of the SLO of /greet.v1.GreetService/Greet must be between 0 and 1, got 99.9`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := TryNewServerMetrics(tc.opts...)
+			require.ErrorContains(t, err, tc.err)
+			require.Panics(t, func() {
+				NewServerMetrics(tc.opts...)
+			})
+		})
+	}
+}
+
+func TestTryNewMetrics_Conventions(t *testing.T) {
+	_, err := TryNewClientMetrics(WithConstLabels(prom.Labels{"podName": "x"}))
+	require.ErrorContains(t, err, "connect_client_started_total: label names should be written in 'snake_case' not 'camelCase'")
+
+	require.NotPanics(t, func() {
+		NewClientMetrics(WithConstLabels(prom.Labels{"podName": "x"}))
+	}, "convention violations are only returned by TryNewClientMetrics")
+}